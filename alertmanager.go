@@ -0,0 +1,193 @@
+package main
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+//defaultResolveTimeout is how long after it was last posted a firing alert resolves, unless configured otherwise
+const defaultResolveTimeout = 15 * time.Minute
+
+//alertmanagerAlertName is the alertname label of every alert inspectr posts
+const alertmanagerAlertName = "ImageUpgradeAvailable"
+
+//AlertmanagerAlert type representing the json schema of an alert posted to alertmanager's /api/v2/alerts
+type AlertmanagerAlert struct {
+	Labels      map[string]string `json:"labels"`
+	Annotations map[string]string `json:"annotations,omitempty"`
+	EndsAt      time.Time         `json:"endsAt"`
+}
+
+//alertmanagerNotifier type: a Notifier that posts an alert for each result to alertmanager, so that its silences and
+// routing apply. It's sent every result after every scan (see everyScanOutputs), and as a Resolver resolves any alerts
+// it's posted that aren't in them any more, i.e. the upgrade has been rolled out (or the workload has gone)
+type alertmanagerNotifier struct {
+	config     AlertmanagerConfig
+	receiver   string
+	alertState *AlertState
+	now        func() time.Time
+}
+
+//alertmanagerNotifier implementation of Notifier
+func (notifier *alertmanagerNotifier) FullReport(upgradeMap map[string][]InspectrResult) error {
+	return notifier.fire(upgradeMap)
+}
+
+//alertmanagerNotifier implementation of Notifier
+func (notifier *alertmanagerNotifier) NewFindings(upgradeMap map[string][]InspectrResult) error {
+	return notifier.fire(upgradeMap)
+}
+
+//alertmanagerNotifier implementation of Notifier. Events aren't posted to alertmanager
+func (notifier *alertmanagerNotifier) Event(event NotifierEvent) error {
+	return nil
+}
+
+//alertmanagerNotifier implementation of Resolver. Firing alerts that aren't in the results are resolved, unless their
+// image's registry couldn't be reached, in which case they're posted again so that they carry on firing
+func (notifier *alertmanagerNotifier) Resolve(upgradeMap map[string][]InspectrResult,
+	registryErrors map[string][]string) (err error) {
+
+	now := notifier.currentTime()
+	var current []map[string]string
+	for _, alert := range notifier.alerts(upgradeMap, now) {
+		current = append(current, alert.Labels)
+	}
+	var firing, alerts []AlertmanagerAlert
+	for _, alert := range notifier.alertState.FiringAlerts[notifier.receiver] {
+		_, registryErrored := registryErrors[registryFromImage(alert.Labels["image"])]
+		if containsLabels(current, alert.Labels) {
+			firing = append(firing, alert)
+		} else if registryErrored {
+			alert.EndsAt = now.Add(notifier.resolveTimeout())
+			firing = append(firing, alert)
+			alerts = append(alerts, alert)
+		} else {
+			alert.EndsAt = now
+			alerts = append(alerts, alert)
+		}
+	}
+	if len(alerts) > 0 {
+		err = notifier.post(alerts)
+	}
+	if err == nil {
+		notifier.alertState.FiringAlerts[notifier.receiver] = firing
+	}
+	return
+}
+
+//fire posts a firing alert for each of the results, and records them as firing
+func (notifier *alertmanagerNotifier) fire(upgradeMap map[string][]InspectrResult) (err error) {
+	alerts := notifier.alerts(upgradeMap, notifier.currentTime())
+	if len(alerts) > 0 {
+		err = notifier.post(alerts)
+	}
+	if err == nil {
+		firing := notifier.alertState.FiringAlerts[notifier.receiver]
+		for _, alert := range alerts {
+			recorded := false
+			for i := range firing {
+				if containsLabels([]map[string]string{firing[i].Labels}, alert.Labels) {
+					firing[i] = alert
+					recorded = true
+				}
+			}
+			if !recorded {
+				firing = append(firing, alert)
+			}
+		}
+		notifier.alertState.FiringAlerts[notifier.receiver] = firing
+	}
+	return
+}
+
+//alerts returns a firing alert for each of the results, ending after the resolve timeout unless they're posted again
+func (notifier *alertmanagerNotifier) alerts(upgradeMap map[string][]InspectrResult, now time.Time) (
+	alerts []AlertmanagerAlert) {
+
+	var keys []string
+	for k := range upgradeMap {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		for _, result := range upgradeMap[k] {
+			labels := map[string]string{
+				"alertname":     alertmanagerAlertName,
+				"project":       projectFromInspectrMapKey(k),
+				"cluster":       clusterFromInspectrMapKey(k),
+				"namespace":     result.Namespace,
+				"workload":      podFromInspectrMapKey(k),
+				"container":     containerFromInspectrMapKey(k),
+				"image":         imageFromInspectrMapKey(k),
+				"upgrade_class": result.UpgradeClass,
+			}
+			if result.Ownership.Team != "" {
+				labels["team"] = result.Ownership.Team
+			}
+			for name, value := range notifier.config.Labels {
+				labels[name] = value
+			}
+			image := imageFromInspectrMapKey(k)
+			alerts = append(alerts, AlertmanagerAlert{
+				Labels: labels,
+				Annotations: map[string]string{
+					"current_version": result.Version,
+					"latest_version":  result.LatestVersion,
+					"versions_behind": strconv.Itoa(result.VersionsBehind),
+					"summary": image + " in " + result.Namespace + " can be upgraded from " + result.Version +
+						" to " + result.LatestVersion,
+				},
+				EndsAt: now.Add(notifier.resolveTimeout()),
+			})
+		}
+	}
+	return
+}
+
+//resolveTimeout returns how long after it's posted a firing alert resolves, unless it's posted again
+func (notifier *alertmanagerNotifier) resolveTimeout() (resolveTimeout time.Duration) {
+	resolveTimeout = defaultResolveTimeout
+	if notifier.config.ResolveTimeout != "" {
+		resolveTimeout, _ = time.ParseDuration(notifier.config.ResolveTimeout)
+	}
+	return
+}
+
+//post posts the alerts to alertmanager. Errors don't include its url, which may have credentials in it
+func (notifier *alertmanagerNotifier) post(alerts []AlertmanagerAlert) error {
+	alertsURL := notifier.config.URL
+	if !strings.HasSuffix(alertsURL, "/") {
+		alertsURL += "/"
+	}
+	return postJSON(notifier.config.HTTP.httpClient(), alertsURL+"api/v2/alerts", "alertmanager", alerts)
+}
+
+//currentTime returns the time now, according to the notifier's clock if it has one
+func (notifier *alertmanagerNotifier) currentTime() time.Time {
+	if notifier.now != nil {
+		return notifier.now()
+	}
+	return time.Now()
+}
+
+//containsLabels returns a bool indicating whether the label sets contain one equal to labels
+func containsLabels(labelSets []map[string]string, labels map[string]string) (containsLabels bool) {
+	for _, labelSet := range labelSets {
+		if len(labelSet) == len(labels) {
+			equal := true
+			for k, v := range labels {
+				if labelSet[k] != v {
+					equal = false
+				}
+			}
+			if equal {
+				containsLabels = true
+				break
+			}
+		}
+	}
+	return
+}
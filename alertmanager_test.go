@@ -0,0 +1,96 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestAlertmanagerNotifier(t *testing.T) {
+	var posts [][]AlertmanagerAlert
+	down := false
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/am/api/v2/alerts" || down {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		var alerts []AlertmanagerAlert
+		json.NewDecoder(r.Body).Decode(&alerts)
+		posts = append(posts, alerts)
+	}))
+	defer server.Close()
+	now := time.Date(2026, 10, 18, 10, 0, 0, 0, time.UTC)
+	alertState := newAlertState()
+	notifier := &alertmanagerNotifier{config: AlertmanagerConfig{URL: server.URL + "/am",
+		Labels: map[string]string{"severity": "info"}}, receiver: defaultReceiver, alertState: alertState,
+		now: func() time.Time { return now }}
+	appKey := "project:prod:eversc/app:app:app"
+	dbKey := "project:prod:eversc/db:db:db"
+	upgradeMap := map[string][]InspectrResult{
+		appKey: {{Namespace: "web", Version: "1.0.0", LatestVersion: "1.1.0", UpgradeClass: "minor"}},
+		dbKey: {{Namespace: "data", Version: "1.0.0", LatestVersion: "2.0.0", UpgradeClass: "major",
+			Ownership: Ownership{Team: "storage"}}},
+	}
+	if err := notifier.FullReport(upgradeMap); err != nil {
+		t.Fatal(err)
+	}
+	if len(posts) != 1 || len(posts[0]) != 2 {
+		t.Fatalf("alertmanager was posted %+v, expected 2 alerts", posts)
+	}
+	app := posts[0][0]
+	if app.Labels["alertname"] != "ImageUpgradeAvailable" || app.Labels["image"] != "eversc/app" ||
+		app.Labels["namespace"] != "web" || app.Labels["upgrade_class"] != "minor" || app.Labels["severity"] != "info" ||
+		app.Annotations["latest_version"] != "1.1.0" || !app.EndsAt.Equal(now.Add(15*time.Minute)) {
+		t.Errorf("alertmanager was posted alert %+v", app)
+	}
+	if v := posts[0][1].Labels["team"]; v != "storage" {
+		t.Errorf("alertmanager was posted team label %s for the db image", v)
+	}
+	if err := notifier.Resolve(upgradeMap, nil); err != nil || len(posts) != 1 {
+		t.Errorf("Resolve with every alert still firing returned %v, posted %+v", err, posts[1:])
+	}
+	//the app upgrade has been rolled out, so its alert resolves
+	now = now.Add(time.Minute)
+	delete(upgradeMap, appKey)
+	if err := notifier.FullReport(upgradeMap); err != nil {
+		t.Fatal(err)
+	}
+	if err := notifier.Resolve(upgradeMap, nil); err != nil {
+		t.Fatal(err)
+	}
+	if len(posts) != 3 || len(posts[1]) != 1 || posts[1][0].Labels["image"] != "eversc/db" || len(posts[2]) != 1 ||
+		posts[2][0].Labels["image"] != "eversc/app" || !posts[2][0].EndsAt.Equal(now) {
+		t.Errorf("alertmanager was posted %+v, expected the db alert firing and the app alert resolved", posts[1:])
+	}
+	if len(alertState.FiringAlerts[defaultReceiver]) != 1 {
+		t.Errorf("firing alerts are %+v, expected just the db alert", alertState.FiringAlerts)
+	}
+	//the db's registry can't be reached, so whether it's been upgraded is unknown and its alert carries on firing
+	now = now.Add(time.Minute)
+	registryErrors := map[string][]string{registryFromImage("eversc/db"): {"registry is down"}}
+	if err := notifier.Resolve(nil, registryErrors); err != nil || len(posts) != 4 || len(posts[3]) != 1 ||
+		!posts[3][0].EndsAt.Equal(now.Add(15*time.Minute)) || posts[3][0].Annotations["latest_version"] != "2.0.0" {
+		t.Errorf("Resolve with the db's registry down returned %v, posted %+v, expected the db alert still firing",
+			err, posts[3:])
+	}
+	if len(alertState.FiringAlerts[defaultReceiver]) != 1 {
+		t.Errorf("firing alerts are %+v with the db's registry down, expected the db alert", alertState.FiringAlerts)
+	}
+	//the db alert is still firing until alertmanager can be told otherwise
+	down = true
+	if err := notifier.Resolve(nil, nil); err == nil || !strings.HasPrefix(err.Error(), "alertmanager returned status 503") {
+		t.Errorf("Resolve to an unavailable alertmanager returned error %v", err)
+	}
+	if len(alertState.FiringAlerts[defaultReceiver]) != 1 {
+		t.Errorf("firing alerts are %+v after a failure, expected the db alert to still be firing",
+			alertState.FiringAlerts)
+	}
+	down = false
+	if err := notifier.Resolve(nil, nil); err != nil || len(posts) != 5 || !posts[4][0].EndsAt.Equal(now) ||
+		len(alertState.FiringAlerts[defaultReceiver]) != 0 {
+		t.Errorf("Resolve with no results returned error %v, posted %+v", err, posts[4:])
+	}
+}
@@ -0,0 +1,148 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+)
+
+//Scan type representing the outcome of a single run through the inspectr process, as served by the results api
+type Scan struct {
+	Start time.Time `json:"start"`
+	End   time.Time `json:"end"`
+	//Errors are keyed by registry, e.g. "quay.io"
+	Errors  map[string][]string `json:"errors"`
+	Results []APIResult         `json:"results"`
+}
+
+//APIResult type representing an InspectrResult along with the infrastructure it was found on
+type APIResult struct {
+	Project        string   `json:"project"`
+	Cluster        string   `json:"cluster"`
+	Namespace      string   `json:"namespace"`
+	Workload       string   `json:"workload"`
+	Container      string   `json:"container"`
+	Image          string   `json:"image"`
+	Quantity       int64    `json:"quantity"`
+	Version        string   `json:"version"`
+	LatestVersion  string   `json:"latestVersion"`
+	Upgrades       []string `json:"upgrades"`
+	VersionsBehind int      `json:"versionsBehind"`
+	DaysBehind     int      `json:"daysBehind"`
+	UpgradeClass   string   `json:"upgradeClass"`
+	//FirstSeen is when each of the Upgrades was first seen by this inspectr process, keyed by upgrade version
+	FirstSeen map[string]time.Time `json:"firstSeen"`
+	//Issue is the URL of the JIRA (or GitHub) issue inspectr is tracking the upgrade in, if any
+	Issue string `json:"issue,omitempty"`
+	//Owner is who owns the workload, from its (or its namespace's) annotations
+	Owner Ownership `json:"owner"`
+}
+
+var (
+	latestScan      *Scan
+	latestScanMutex sync.RWMutex
+)
+
+//newScan returns a Scan built from the specified (unfiltered) upgrade map, ordered by cluster, namespace, workload
+// and container so that responses are stable between scans
+func newScan(start, end time.Time, upgradeMap map[string][]InspectrResult, registryErrors map[string][]string) *Scan {
+	scan := &Scan{Start: start, End: end, Errors: registryErrors, Results: make([]APIResult, 0)}
+	for k, v := range upgradeMap {
+		for _, result := range v {
+			scan.Results = append(scan.Results, apiResult(k, result))
+		}
+	}
+	sort.Slice(scan.Results, func(i, j int) bool {
+		a, b := scan.Results[i], scan.Results[j]
+		if a.Cluster != b.Cluster {
+			return a.Cluster < b.Cluster
+		}
+		if a.Namespace != b.Namespace {
+			return a.Namespace < b.Namespace
+		}
+		if a.Workload != b.Workload {
+			return a.Workload < b.Workload
+		}
+		if a.Container != b.Container {
+			return a.Container < b.Container
+		}
+		return a.Version < b.Version
+	})
+	return scan
+}
+
+//apiResult returns an APIResult representing the InspectrResult found at the specified inspectr map key
+func apiResult(mapKey string, result InspectrResult) APIResult {
+	return APIResult{
+		Project:        projectFromInspectrMapKey(mapKey),
+		Cluster:        clusterFromInspectrMapKey(mapKey),
+		Namespace:      result.Namespace,
+		Workload:       podFromInspectrMapKey(mapKey),
+		Container:      containerFromInspectrMapKey(mapKey),
+		Image:          imageFromInspectrMapKey(mapKey),
+		Quantity:       result.Quantity,
+		Version:        result.Version,
+		LatestVersion:  result.LatestVersion,
+		Upgrades:       result.Upgrades,
+		VersionsBehind: result.VersionsBehind,
+		DaysBehind:     result.DaysBehind,
+		UpgradeClass:   result.UpgradeClass,
+		FirstSeen:      upgradesFirstSeen(mapKey, result),
+		Issue:          issueURL(mapKey),
+		Owner:          result.Ownership,
+	}
+}
+
+//setLatestScan replaces the scan served by the results api
+func setLatestScan(scan *Scan) {
+	latestScanMutex.Lock()
+	defer latestScanMutex.Unlock()
+	latestScan = scan
+}
+
+//getLatestScan returns the scan served by the results api, or nil if no scan has completed yet
+func getLatestScan() *Scan {
+	latestScanMutex.RLock()
+	defer latestScanMutex.RUnlock()
+	return latestScan
+}
+
+//handleResults serves the latest scan as json. Results can be filtered with the cluster, namespace, image, class and
+// team query parameters, each of which may be repeated to match any of several values
+func handleResults(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" && r.Method != "HEAD" {
+		w.Header().Set("Allow", "GET, HEAD")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	scan := getLatestScan()
+	if scan == nil {
+		http.Error(w, "no scan has completed yet", http.StatusServiceUnavailable)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	json.NewEncoder(w).Encode(filterScan(scan, r.URL.Query()))
+}
+
+//filterScan returns a copy of the scan with only those results matching every filter specified
+func filterScan(scan *Scan, filters map[string][]string) *Scan {
+	filtered := &Scan{Start: scan.Start, End: scan.End, Errors: scan.Errors, Results: make([]APIResult, 0)}
+	for _, result := range scan.Results {
+		if matchesFilter(filters["cluster"], result.Cluster) &&
+			matchesFilter(filters["namespace"], result.Namespace) &&
+			matchesFilter(filters["image"], result.Image) &&
+			matchesFilter(filters["class"], result.UpgradeClass) &&
+			matchesFilter(filters["team"], result.Owner.Team) {
+			filtered.Results = append(filtered.Results, result)
+		}
+	}
+	return filtered
+}
+
+//matchesFilter returns a bool indicating whether the value is one of those allowed. No allowed values means anything
+// matches
+func matchesFilter(allowed []string, value string) bool {
+	return len(allowed) == 0 || contains(allowed, value)
+}
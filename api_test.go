@@ -0,0 +1,64 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+var resultsRequests = []struct {
+	query   string
+	status  int
+	results int
+}{
+	{"", http.StatusOK, 3},
+	{"?namespace=banana-namespace", http.StatusOK, 2},
+	{"?namespace=banana-namespace&class=major", http.StatusOK, 1},
+	{"?namespace=banana-namespace&namespace=apples-namespace", http.StatusOK, 3},
+	{"?image=eversc/apples", http.StatusOK, 1},
+	{"?cluster=pears", http.StatusOK, 0},
+	{"?team=fruit", http.StatusOK, 1},
+}
+
+func TestHandleResults(t *testing.T) {
+	setLatestScan(nil)
+	recorder := httptest.NewRecorder()
+	handleResults(recorder, httptest.NewRequest("GET", "/api/v1/results", nil))
+	if recorder.Code != http.StatusServiceUnavailable {
+		t.Errorf("handleResults before any scan returned %d, expected %d", recorder.Code,
+			http.StatusServiceUnavailable)
+	}
+
+	upgradeMap := map[string][]InspectrResult{
+		"project:cluster:eversc/banana:banana:banana": {
+			{Name: "eversc/banana", Namespace: "banana-namespace", Version: "v1.0.0", UpgradeClass: "major"},
+			{Name: "eversc/banana", Namespace: "banana-namespace", Version: "v2.0.0", UpgradeClass: "minor"},
+		},
+		"project:cluster:eversc/apples:apples:apples": {
+			{Name: "eversc/apples", Namespace: "apples-namespace", Version: "v1.0.0", UpgradeClass: "patch",
+				Ownership: Ownership{Team: "fruit"}},
+		},
+	}
+	registryErrors := map[string][]string{"quay.io": {"bad status code (500)"}}
+	setLatestScan(newScan(time.Now(), time.Now(), upgradeMap, registryErrors))
+	for _, resultsRequest := range resultsRequests {
+		recorder := httptest.NewRecorder()
+		handleResults(recorder, httptest.NewRequest("GET", "/api/v1/results"+resultsRequest.query, nil))
+		var scan Scan
+		err := json.NewDecoder(recorder.Body).Decode(&scan)
+		if recorder.Code != resultsRequest.status || err != nil || len(scan.Results) != resultsRequest.results ||
+			len(scan.Errors["quay.io"]) != 1 {
+			t.Errorf("handleResults(%s) returned %d with %d results (error: %v), expected %d with %d results",
+				resultsRequest.query, recorder.Code, len(scan.Results), err, resultsRequest.status,
+				resultsRequest.results)
+		}
+	}
+
+	recorder = httptest.NewRecorder()
+	handleResults(recorder, httptest.NewRequest("POST", "/api/v1/results", nil))
+	if recorder.Code != http.StatusMethodNotAllowed {
+		t.Errorf("handleResults with POST returned %d, expected %d", recorder.Code, http.StatusMethodNotAllowed)
+	}
+}
@@ -0,0 +1,878 @@
+package main
+
+import (
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/golang/glog"
+	version "github.com/hashicorp/go-version"
+	"gopkg.in/yaml.v2"
+)
+
+//Config type representing the inspectr config file (INSPECTR_CONFIG)
+type Config struct {
+	Clusters   []ClusterConfig  `yaml:"clusters"`
+	Registries []RegistryConfig `yaml:"registries"`
+	Filters    FiltersConfig    `yaml:"filters"`
+	Policies   []VersionPolicy  `yaml:"policies"`
+	Outputs    OutputsConfig    `yaml:"outputs"`
+	Receivers  []ReceiverConfig `yaml:"receivers"`
+	Route      RouteConfig      `yaml:"route"`
+	Schedule   string           `yaml:"schedule"`
+	Timezone   string           `yaml:"timezone"`
+
+	schedules map[string]*Schedule
+	location  *time.Location
+	receivers map[string]ReceiverConfig
+	//envVarProblems are the ${VAR}s in the config whose env vars aren't set, reported by validate
+	envVarProblems []string
+}
+
+//ClusterConfig type representing a cluster to scan. An empty APIServer is the cluster inspectr is running in, and an
+// empty Name/Project is looked up from the compute metadata api. If there are Namespaces, pods are listed in each of
+// them rather than cluster-wide, so inspectr only needs RBAC in those namespaces
+type ClusterConfig struct {
+	Name       string   `yaml:"name"`
+	Project    string   `yaml:"project"`
+	APIServer  string   `yaml:"apiServer"`
+	TokenFile  string   `yaml:"tokenFile"`
+	CAFile     string   `yaml:"caFile"`
+	Namespaces []string `yaml:"namespaces"`
+}
+
+//RegistryConfig type representing a registry, and which api should be used to list its tags: gcr, v2 or dockerhub
+type RegistryConfig struct {
+	Host string `yaml:"host"`
+	API  string `yaml:"api"`
+}
+
+//FiltersConfig type representing what gets left out of results. Namespaces are globs, and selectors are k8s label
+// selectors, e.g. "team=payments,tier!=db"
+type FiltersConfig struct {
+	IncludeNamespaces []string            `yaml:"includeNamespaces"`
+	IgnoreNamespaces  []string            `yaml:"ignoreNamespaces"`
+	PodSelector       string              `yaml:"podSelector"`
+	NamespaceSelector string              `yaml:"namespaceSelector"`
+	IgnoreTags        []string            `yaml:"ignoreTags"`
+	IgnoreImages      map[string][]string `yaml:"ignoreImages"`
+	AllowedPodPhases  []string            `yaml:"allowedPodPhases"`
+}
+
+//VersionPolicy type representing which upgrades are reported for images matching any of the Images globs
+type VersionPolicy struct {
+	Images            []string `yaml:"images"`
+	IgnorePrereleases bool     `yaml:"ignorePrereleases"`
+	TagPattern        string   `yaml:"tagPattern"`
+	UpgradeClasses    []string `yaml:"upgradeClasses"`
+
+	tagRegexp *regexp.Regexp
+}
+
+//OutputsConfig type representing where results are sent
+type OutputsConfig struct {
+	Slack        SlackConfig        `yaml:"slack"`
+	Teams        TeamsConfig        `yaml:"teams"`
+	Webhook      WebhookConfig      `yaml:"webhook"`
+	Email        EmailConfig        `yaml:"email"`
+	Alertmanager AlertmanagerConfig `yaml:"alertmanager"`
+	Jira         JiraConfig         `yaml:"jira"`
+	GitHub       GitHubConfig       `yaml:"github"`
+	GitLab       GitLabConfig       `yaml:"gitlab"`
+}
+
+//SlackConfig type representing the slack output, which posts to an incoming webhook (WebhookID, which can be the
+// webhook's full url) or, with a bot Token, to a Channel through the Web API at APIURL (https://slack.com/api/ if
+// empty). It's disabled if neither WebhookID nor Token is set, or Disabled is set
+type SlackConfig struct {
+	WebhookID string     `yaml:"webhookID"`
+	Token     string     `yaml:"token"`
+	Channel   string     `yaml:"channel"`
+	APIURL    string     `yaml:"apiURL"`
+	HTTP      HTTPConfig `yaml:"http"`
+	Schedule  string     `yaml:"schedule"`
+	Disabled  bool       `yaml:"disabled"`
+}
+
+//TeamsConfig type representing the Microsoft Teams output, which posts to an incoming webhook. It's disabled if
+// WebhookURL is empty, or Disabled is set
+type TeamsConfig struct {
+	WebhookURL string     `yaml:"webhookURL"`
+	HTTP       HTTPConfig `yaml:"http"`
+	Schedule   string     `yaml:"schedule"`
+	Disabled   bool       `yaml:"disabled"`
+}
+
+//WebhookConfig type representing the generic webhook output, which sends a request with the specified Method
+// (POST if empty) and Headers to URL, with a body rendered from the text/template Template (the json of its data, see
+// WebhookPayload, if empty). If there's a Secret, the body's HMAC-SHA256 is sent in SignatureHeader
+// (X-Inspectr-Signature if empty). It's disabled if URL is empty, or Disabled is set
+type WebhookConfig struct {
+	URL             string            `yaml:"url"`
+	Method          string            `yaml:"method"`
+	Headers         map[string]string `yaml:"headers"`
+	Template        string            `yaml:"template"`
+	Secret          string            `yaml:"secret"`
+	SignatureHeader string            `yaml:"signatureHeader"`
+	HTTP            HTTPConfig        `yaml:"http"`
+	Schedule        string            `yaml:"schedule"`
+	Disabled        bool              `yaml:"disabled"`
+
+	template *template.Template
+}
+
+//EmailConfig type representing the email output, which sends each full report as a digest to To, through the SMTP
+// server at Host:Port (587 if Port is 0). StartTLS is auto (used if the server offers it, the default), required or
+// disabled, and there's PLAIN auth if Username is set. It's disabled if Host or To is empty, or Disabled is set
+type EmailConfig struct {
+	Host     string   `yaml:"host"`
+	Port     int      `yaml:"port"`
+	StartTLS string   `yaml:"startTLS"`
+	Username string   `yaml:"username"`
+	Password string   `yaml:"password"`
+	From     string   `yaml:"from"`
+	To       []string `yaml:"to"`
+	Schedule string   `yaml:"schedule"`
+	Disabled bool     `yaml:"disabled"`
+}
+
+//AlertmanagerConfig type representing the alertmanager output, which posts an alert for each result to the
+// alertmanager at URL every scan, with the specified Labels as well as the result's. Firing alerts resolve after
+// ResolveTimeout (a duration, 15m if empty) if they aren't posted again. It's disabled if URL is empty, or Disabled is
+// set
+type AlertmanagerConfig struct {
+	URL            string            `yaml:"url"`
+	Labels         map[string]string `yaml:"labels"`
+	ResolveTimeout string            `yaml:"resolveTimeout"`
+	HTTP           HTTPConfig        `yaml:"http"`
+	Disabled       bool              `yaml:"disabled"`
+}
+
+//JiraConfig type representing the JIRA output. It's disabled if URL is empty, or Disabled is set. Fields keys should
+// be as they appear in the JIRA UI. If CloseTransition is set, issues are closed with it once their image has no
+// upgrades left (or isn't running any more), and reopened with ReopenTransition, if that's set, if upgrades reappear
+type JiraConfig struct {
+	URL              string            `yaml:"url"`
+	User             string            `yaml:"user"`
+	Password         string            `yaml:"password"`
+	Project          string            `yaml:"project"`
+	IssueType        string            `yaml:"issueType"`
+	Fields           map[string]string `yaml:"fields"`
+	CloseTransition  string            `yaml:"closeTransition"`
+	ReopenTransition string            `yaml:"reopenTransition"`
+	Schedule         string            `yaml:"schedule"`
+	Disabled         bool              `yaml:"disabled"`
+}
+
+//GitHubConfig type representing the GitHub issues output, which creates (or comments on) an issue in Repo
+// ("[owner]/[name]") for each image, with Labels (["inspectr"] if nil). URL is the api's, https://api.github.com/ if
+// empty, e.g. https://[host]/api/v3/ for GitHub Enterprise. It's disabled if Repo is empty, or Disabled is set
+type GitHubConfig struct {
+	URL      string     `yaml:"url"`
+	Token    string     `yaml:"token"`
+	Repo     string     `yaml:"repo"`
+	Labels   []string   `yaml:"labels"`
+	HTTP     HTTPConfig `yaml:"http"`
+	Schedule string     `yaml:"schedule"`
+	Disabled bool       `yaml:"disabled"`
+}
+
+//GitLabConfig type representing the GitLab issues output, which creates (or comments on) an issue in Project (its id
+// or path, e.g. "group/name") for each image, with Labels (["inspectr"] if nil). URL is the api's,
+// https://gitlab.com/api/v4/ if empty, e.g. https://[host]/api/v4/ for a self-hosted GitLab. It's disabled if Project is
+// empty, or Disabled is set
+type GitLabConfig struct {
+	URL      string     `yaml:"url"`
+	Token    string     `yaml:"token"`
+	Project  string     `yaml:"project"`
+	Labels   []string   `yaml:"labels"`
+	HTTP     HTTPConfig `yaml:"http"`
+	Schedule string     `yaml:"schedule"`
+	Disabled bool       `yaml:"disabled"`
+}
+
+//the registries and version policies applied to every scan, set from the config by applyConfig
+var (
+	registryConfigs []RegistryConfig
+	versionPolicies []VersionPolicy
+)
+
+//defaultRegistries are checked after any configured registries
+var defaultRegistries = []RegistryConfig{
+	{Host: "gcr.io", API: "gcr"},
+	{Host: "quay.io", API: "v2"},
+	{Host: "zalan.do", API: "v2"},
+}
+
+var (
+	envVarRegexp        = regexp.MustCompile(`\$\{([A-Za-z_][A-Za-z0-9_]*)\}`)
+	validRegistryAPIs   = []string{"gcr", "v2", "dockerhub"}
+	validUpgradeClasses = []string{"major", "minor", "patch", "unknown"}
+	validWebhookMethods = []string{"POST", "PUT", "PATCH"}
+	labelNameRegexp     = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*$`)
+)
+
+func init() {
+	config := &Config{}
+	config.setDefaults()
+	applyConfig(config)
+}
+
+//setDefaults fills in anything the config file leaves out with inspectr's defaults. An explicitly empty list (e.g.
+// "ignoreNamespaces: []") is left empty
+func (config *Config) setDefaults() {
+	if config.Clusters == nil {
+		config.Clusters = []ClusterConfig{{}}
+	}
+	if config.Filters.IgnoreNamespaces == nil {
+		config.Filters.IgnoreNamespaces = []string{"kube-system"}
+	}
+	if config.Filters.IgnoreTags == nil {
+		config.Filters.IgnoreTags = []string{"latest"}
+	}
+	if config.Filters.IgnoreImages == nil {
+		config.Filters.IgnoreImages = map[string][]string{
+			"gcr.io/google_containers/nginx-ingress-controller": {"0.61", "0.62"}}
+	}
+	if config.Filters.AllowedPodPhases == nil {
+		config.Filters.AllowedPodPhases = []string{"Running"}
+	}
+}
+
+//ConfigFile type representing the config file, which is reloaded whenever its contents change so that e.g. a new
+// ignore rule doesn't need a restart (which would clear the alert cache)
+type ConfigFile struct {
+	Path       string
+	Generation int
+	config     *Config
+	hash       [sha256.Size]byte
+}
+
+//loadConfigFile returns the ConfigFile at the specified path (or the defaults if the path is empty), with the config
+// in it loaded. Unlike a reload, an invalid config is an error
+//
+// ${VAR} in any string value in the file is replaced with the value of the VAR env var, so secrets needn't be in the
+// file itself
+func loadConfigFile(configPath string) (configFile *ConfigFile, err error) {
+	configFile = &ConfigFile{Path: configPath}
+	var data []byte
+	data, err = configFile.read()
+	if err == nil {
+		configFile.config, err = parseConfig(data, os.LookupEnv)
+		if err == nil {
+			configFile.hash = sha256.Sum256(data)
+			configFile.Generation = 1
+			configGeneration.Set(1)
+		} else if configPath != "" {
+			err = errors.New(configPath + ": " + err.Error())
+		}
+	}
+	if err != nil {
+		configFile = nil
+	}
+	return
+}
+
+//read returns the contents of the config file, or nothing if there's no config file
+func (configFile *ConfigFile) read() (data []byte, err error) {
+	if configFile.Path != "" {
+		data, err = ioutil.ReadFile(configFile.Path)
+	}
+	return
+}
+
+//reload returns the config, having re-read the config file. If the file's contents have changed, the config in it
+// becomes the config, with what changed logged. If it can't be read or is invalid, that's logged and the previous
+// config is kept
+func (configFile *ConfigFile) reload() (config *Config) {
+	data, err := configFile.read()
+	if err == nil {
+		hash := sha256.Sum256(data)
+		if hash != configFile.hash {
+			configFile.hash = hash
+			var newConfig *Config
+			newConfig, err = parseConfig(data, os.LookupEnv)
+			if err == nil {
+				for _, change := range configDiff(configFile.config, newConfig) {
+					glog.Info("config changed: " + change)
+				}
+				configFile.config = newConfig
+				configFile.Generation++
+				configGeneration.Set(float64(configFile.Generation))
+			}
+		}
+	}
+	if err != nil {
+		glog.Error(configFile.Path+": "+err.Error(), ", keeping the previous config")
+	}
+	config = configFile.config
+	return
+}
+
+//configDiff returns a description of each setting that differs between the old and new config, e.g.
+// "filters.ignoreTags[1]: (added) -> edge". Passwords and webhook ids are redacted
+func configDiff(oldConfig, newConfig *Config) (changes []string) {
+	oldSettings := configSettings(oldConfig)
+	newSettings := configSettings(newConfig)
+	for key, oldValue := range oldSettings {
+		if newValue, ok := newSettings[key]; !ok {
+			changes = append(changes, key+": "+settingString(key, oldValue)+" -> (removed)")
+		} else if newValue != oldValue {
+			changes = append(changes, key+": "+settingString(key, oldValue)+" -> "+settingString(key, newValue))
+		}
+	}
+	for key, newValue := range newSettings {
+		if _, ok := oldSettings[key]; !ok {
+			changes = append(changes, key+": (added) -> "+settingString(key, newValue))
+		}
+	}
+	sort.Strings(changes)
+	return
+}
+
+//configSettings returns a map of setting path (e.g. "outputs.jira.project") to its value in the specified config
+func configSettings(config *Config) (settings map[string]string) {
+	settings = make(map[string]string)
+	var tree interface{}
+	data, err := yaml.Marshal(config)
+	if err == nil {
+		err = yaml.Unmarshal(data, &tree)
+	}
+	if err == nil {
+		flattenSettings("", tree, settings)
+	}
+	return
+}
+
+//flattenSettings adds each leaf of the specified yaml tree to settings, keyed by its path
+func flattenSettings(prefix string, tree interface{}, settings map[string]string) {
+	switch node := tree.(type) {
+	case map[interface{}]interface{}:
+		for k, v := range node {
+			key := fmt.Sprint(k)
+			if prefix != "" {
+				key = prefix + "." + key
+			}
+			flattenSettings(key, v, settings)
+		}
+	case []interface{}:
+		for i, v := range node {
+			flattenSettings(prefix+"["+strconv.Itoa(i)+"]", v, settings)
+		}
+	default:
+		settings[prefix] = fmt.Sprint(node)
+	}
+}
+
+//settingString returns the specified setting's value as it should be logged: quoted if it's empty, and redacted if
+// it's a password, webhook, token, or a url that might have credentials in it (a webhook or alertmanager's, which
+// often carry a token in the path or query, or any url with a user:password@)
+func settingString(key, value string) (str string) {
+	parsedURL, urlErr := url.Parse(value)
+	switch {
+	case strings.HasSuffix(key, ".password") || strings.HasSuffix(key, ".webhookID") ||
+		strings.HasSuffix(key, ".webhookURL") || strings.HasSuffix(key, ".token") ||
+		strings.HasSuffix(key, ".secret") || strings.Contains(key, ".headers.") ||
+		strings.HasSuffix(key, ".webhook.url") || strings.HasSuffix(key, ".alertmanager.url") ||
+		(urlErr == nil && parsedURL.User != nil):
+		str = "(redacted)"
+	case value == "":
+		str = `""`
+	default:
+		str = value
+	}
+	return
+}
+
+//parseConfig returns the config represented by the specified yaml, with env vars (as looked up by lookupEnv) expanded
+// and applied over the top, and validated. Unknown fields are an error, as are ${VAR}s whose env vars aren't set
+func parseConfig(data []byte, lookupEnv func(string) (string, bool)) (config *Config, err error) {
+	config = &Config{}
+	err = yaml.UnmarshalStrict(data, config)
+	if err == nil {
+		config.envVarProblems = expandEnvVars(reflect.ValueOf(config).Elem(), "", lookupEnv)
+		config.setDefaults()
+		err = applyEnvOverrides(config, func(key string) string {
+			value, _ := lookupEnv(key)
+			return value
+		})
+		if err == nil {
+			if config.Outputs.Jira.URL != "" && !strings.HasSuffix(config.Outputs.Jira.URL, "/") {
+				config.Outputs.Jira.URL += "/"
+			}
+			err = config.validate()
+		}
+	}
+	if err != nil {
+		config = nil
+	}
+	return
+}
+
+//expandEnvVars replaces each ${VAR} in the strings in value (walking its exported struct fields, pointers, slices and
+// maps) with the value of the VAR env var, as looked up by lookupEnv. It's done to the decoded values rather than the
+// yaml, so an env var's value is never parsed as yaml. A problem is returned, at the string's path, for each env var
+// that isn't set
+func expandEnvVars(value reflect.Value, path string, lookupEnv func(string) (string, bool)) (problems []string) {
+	switch value.Kind() {
+	case reflect.Ptr:
+		if !value.IsNil() {
+			problems = expandEnvVars(value.Elem(), path, lookupEnv)
+		}
+	case reflect.Struct:
+		for i := 0; i < value.NumField(); i++ {
+			field := value.Type().Field(i)
+			if field.PkgPath == "" {
+				fieldPath := strings.Split(field.Tag.Get("yaml"), ",")[0]
+				if path != "" {
+					fieldPath = path + "." + fieldPath
+				}
+				problems = append(problems, expandEnvVars(value.Field(i), fieldPath, lookupEnv)...)
+			}
+		}
+	case reflect.Slice:
+		for i := 0; i < value.Len(); i++ {
+			problems = append(problems, expandEnvVars(value.Index(i), path+"["+strconv.Itoa(i)+"]", lookupEnv)...)
+		}
+	case reflect.Map:
+		for _, key := range value.MapKeys() {
+			//map values aren't addressable, so each is expanded in a copy that then replaces it
+			elem := reflect.New(value.Type().Elem()).Elem()
+			elem.Set(value.MapIndex(key))
+			problems = append(problems, expandEnvVars(elem, path+"."+fmt.Sprint(key.Interface()), lookupEnv)...)
+			value.SetMapIndex(key, elem)
+		}
+	case reflect.String:
+		value.SetString(envVarRegexp.ReplaceAllStringFunc(value.String(), func(match string) string {
+			name := envVarRegexp.FindStringSubmatch(match)[1]
+			envValue, ok := lookupEnv(name)
+			if !ok {
+				problems = append(problems, path+": env var "+name+" isn't set")
+			}
+			return envValue
+		}))
+	}
+	return
+}
+
+//applyEnvOverrides overwrites config values with any of the INSPECTR_ env vars that are set, which is how inspectr
+// was configured before it had a config file
+func applyEnvOverrides(config *Config, getenv func(string) string) (err error) {
+	overrides := []struct {
+		key   string
+		value *string
+	}{
+		{"INSPECTR_SLACK_WEBHOOK_ID", &config.Outputs.Slack.WebhookID},
+		{"INSPECTR_SLACK_TOKEN", &config.Outputs.Slack.Token},
+		{"INSPECTR_SLACK_CHANNEL", &config.Outputs.Slack.Channel},
+		{"INSPECTR_SLACK_SCHEDULE", &config.Outputs.Slack.Schedule},
+		{"INSPECTR_TEAMS_WEBHOOK_URL", &config.Outputs.Teams.WebhookURL},
+		{"INSPECTR_TEAMS_SCHEDULE", &config.Outputs.Teams.Schedule},
+		{"INSPECTR_WEBHOOK_URL", &config.Outputs.Webhook.URL},
+		{"INSPECTR_WEBHOOK_SECRET", &config.Outputs.Webhook.Secret},
+		{"INSPECTR_WEBHOOK_SCHEDULE", &config.Outputs.Webhook.Schedule},
+		{"INSPECTR_EMAIL_HOST", &config.Outputs.Email.Host},
+		{"INSPECTR_EMAIL_USERNAME", &config.Outputs.Email.Username},
+		{"INSPECTR_EMAIL_PASSWORD", &config.Outputs.Email.Password},
+		{"INSPECTR_EMAIL_SCHEDULE", &config.Outputs.Email.Schedule},
+		{"INSPECTR_ALERTMANAGER_URL", &config.Outputs.Alertmanager.URL},
+		{"INSPECTR_JIRA_URL", &config.Outputs.Jira.URL},
+		{"INSPECTR_JIRA_USER", &config.Outputs.Jira.User},
+		{"INSPECTR_JIRA_PASSWORD", &config.Outputs.Jira.Password},
+		{"INSPECTR_JIRA_SCHEDULE", &config.Outputs.Jira.Schedule},
+		{"INSPECTR_GITHUB_TOKEN", &config.Outputs.GitHub.Token},
+		{"INSPECTR_GITHUB_SCHEDULE", &config.Outputs.GitHub.Schedule},
+		{"INSPECTR_GITLAB_TOKEN", &config.Outputs.GitLab.Token},
+		{"INSPECTR_GITLAB_SCHEDULE", &config.Outputs.GitLab.Schedule},
+		{"INSPECTR_SCHEDULE", &config.Schedule},
+		{"INSPECTR_TIMEZONE", &config.Timezone},
+	}
+	for _, override := range overrides {
+		if value := getenv(override.key); value != "" {
+			*override.value = value
+		}
+	}
+	if jiraParams := getenv("INSPECTR_JIRA_PARAMS"); jiraParams != "" {
+		err = parseJiraParams(jiraParams, &config.Outputs.Jira)
+	}
+	return
+}
+
+//parseJiraParams sets the JIRA auth and other details from the legacy INSPECTR_JIRA_PARAMS string, which should be of
+// the form:
+//     user|pass|project|issueType|otherFieldKey:otherFieldValue,otherFieldKey:otherFieldValue
+//
+//  user, pass, project and issueType are mandatory
+//  1:n otherField k:v are optional  (but could be mandatory in your JIRA project)
+func parseJiraParams(jiraParamString string, jiraConfig *JiraConfig) (err error) {
+	jiraParamStrings := strings.Split(jiraParamString, "|")
+	if len(jiraParamStrings) > 3 {
+		jiraConfig.User = jiraParamStrings[0]
+		jiraConfig.Password = jiraParamStrings[1]
+		jiraConfig.Project = jiraParamStrings[2]
+		jiraConfig.IssueType = jiraParamStrings[3]
+		if len(jiraParamStrings) > 4 && jiraParamStrings[4] != "" {
+			if jiraConfig.Fields == nil {
+				jiraConfig.Fields = make(map[string]string)
+			}
+			for _, otherField := range strings.Split(jiraParamStrings[4], ",") {
+				keyValueStrings := strings.Split(otherField, ":")
+				if len(keyValueStrings) == 2 {
+					jiraConfig.Fields[keyValueStrings[0]] = keyValueStrings[1]
+				}
+			}
+		}
+	} else {
+		err = errors.New("INSPECTR_JIRA_PARAMS specified but not enough params found. " +
+			"Usage: user|pass|project|issueType|otherFieldKey:otherFieldValue,otherFieldKey:otherFieldValue...")
+	}
+	return
+}
+
+//validate checks the config, returning an error listing every problem found (by its path in the config file). It
+// also parses the schedules, timezone and tag patterns, so they're ready to use
+func (config *Config) validate() (err error) {
+	problems := append([]string(nil), config.envVarProblems...)
+	problem := func(path, message string) {
+		problems = append(problems, path+": "+message)
+	}
+	if len(config.Clusters) == 0 {
+		problem("clusters", "at least one cluster is required")
+	}
+	clusterNames := make(map[string]struct{})
+	for i, cluster := range config.Clusters {
+		clusterPath := "clusters[" + strconv.Itoa(i) + "]"
+		if cluster.APIServer != "" {
+			if cluster.Name == "" {
+				problem(clusterPath+".name", "required when apiServer is set")
+			}
+			if cluster.TokenFile == "" {
+				problem(clusterPath+".tokenFile", "required when apiServer is set")
+			}
+			if !strings.HasPrefix(cluster.APIServer, "https://") &&
+				!strings.HasPrefix(cluster.APIServer, "http://") {
+				problem(clusterPath+".apiServer", "must be an http(s) url")
+			}
+		} else if cluster.TokenFile != "" || cluster.CAFile != "" {
+			problem(clusterPath, "tokenFile and caFile are only used with apiServer")
+		}
+		if len(cluster.Namespaces) > 0 && config.Filters.NamespaceSelector != "" {
+			problem(clusterPath+".namespaces", "can't be used with filters.namespaceSelector, which needs to list "+
+				"namespaces cluster-wide")
+		}
+		if _, ok := clusterNames[cluster.Name]; ok {
+			problem(clusterPath+".name", "duplicate cluster \""+cluster.Name+"\"")
+		}
+		clusterNames[cluster.Name] = struct{}{}
+	}
+	namespaceGlobs := map[string][]string{
+		"filters.includeNamespaces": config.Filters.IncludeNamespaces,
+		"filters.ignoreNamespaces":  config.Filters.IgnoreNamespaces,
+	}
+	for globsPath, globs := range namespaceGlobs {
+		for i, glob := range globs {
+			if _, matchErr := path.Match(glob, ""); matchErr != nil {
+				problem(globsPath+"["+strconv.Itoa(i)+"]", "invalid glob \""+glob+"\"")
+			}
+		}
+	}
+	for i, registry := range config.Registries {
+		registryPath := "registries[" + strconv.Itoa(i) + "]"
+		if registry.Host == "" {
+			problem(registryPath+".host", "required")
+		}
+		if !contains(validRegistryAPIs, registry.API) {
+			problem(registryPath+".api", "must be one of "+strings.Join(validRegistryAPIs, ", "))
+		}
+	}
+	for i := range config.Policies {
+		policy := &config.Policies[i]
+		policyPath := "policies[" + strconv.Itoa(i) + "]"
+		if len(policy.Images) == 0 {
+			problem(policyPath+".images", "at least one image glob is required")
+		}
+		for j, image := range policy.Images {
+			if _, matchErr := path.Match(image, ""); matchErr != nil {
+				problem(policyPath+".images["+strconv.Itoa(j)+"]", "invalid glob \""+image+"\"")
+			}
+		}
+		if policy.TagPattern != "" {
+			var regexpErr error
+			policy.tagRegexp, regexpErr = regexp.Compile(policy.TagPattern)
+			if regexpErr != nil {
+				problem(policyPath+".tagPattern", regexpErr.Error())
+			}
+		}
+		for j, class := range policy.UpgradeClasses {
+			if !contains(validUpgradeClasses, class) {
+				problem(policyPath+".upgradeClasses["+strconv.Itoa(j)+"]",
+					"must be one of "+strings.Join(validUpgradeClasses, ", "))
+			}
+		}
+	}
+	validateSlack(&config.Outputs.Slack, "outputs.slack", problem)
+	validateTeams(&config.Outputs.Teams, "outputs.teams", problem)
+	validateWebhook(&config.Outputs.Webhook, "outputs.webhook", problem)
+	validateEmail(&config.Outputs.Email, "outputs.email", problem)
+	validateAlertmanager(&config.Outputs.Alertmanager, "outputs.alertmanager", problem)
+	validateGitHub(&config.Outputs.GitHub, "outputs.github", problem)
+	if config.Outputs.GitHub.Repo != "" && config.Outputs.GitHub.Token == "" {
+		problem("outputs.github.token", "required when outputs.github.repo is set")
+	}
+	validateGitLab(&config.Outputs.GitLab, "outputs.gitlab", problem)
+	if config.Outputs.GitLab.Project != "" && config.Outputs.GitLab.Token == "" {
+		problem("outputs.gitlab.token", "required when outputs.gitlab.project is set")
+	}
+	if config.Outputs.Email.Host != "" && config.Outputs.Email.From == "" {
+		problem("outputs.email.from", "required when outputs.email.host is set")
+	}
+	jiraConfig := config.Outputs.Jira
+	if jiraConfig.URL != "" {
+		requiredFields := []struct {
+			name  string
+			value string
+		}{
+			{"user", jiraConfig.User},
+			{"password", jiraConfig.Password},
+			{"project", jiraConfig.Project},
+			{"issueType", jiraConfig.IssueType},
+		}
+		for _, field := range requiredFields {
+			if field.value == "" {
+				problem("outputs.jira."+field.name, "required when outputs.jira.url is set")
+			}
+		}
+	}
+	validateJira(&config.Outputs.Jira, "outputs.jira", problem)
+	validateRoutes(config, problem)
+	config.receivers = resolveReceivers(config)
+	if _, scheduleErr := parseSchedule(config.Schedule); scheduleErr != nil {
+		problem("schedule", scheduleErr.Error())
+	}
+	outputScheduleStrings := map[string]string{
+		"slack":   config.Outputs.Slack.Schedule,
+		"teams":   config.Outputs.Teams.Schedule,
+		"webhook": config.Outputs.Webhook.Schedule,
+		"email":   config.Outputs.Email.Schedule,
+		"jira":    jiraConfig.Schedule,
+		"github":  config.Outputs.GitHub.Schedule,
+		"gitlab":  config.Outputs.GitLab.Schedule,
+	}
+	for output, scheduleString := range outputScheduleStrings {
+		if _, scheduleErr := parseSchedule(scheduleString); scheduleString != "" && scheduleErr != nil {
+			problem("outputs."+output+".schedule", scheduleErr.Error())
+		}
+	}
+	config.schedules, _ = outputSchedules(config.Schedule, outputScheduleStrings)
+	var locationErr error
+	config.location, locationErr = time.LoadLocation(config.Timezone)
+	if locationErr != nil {
+		problem("timezone", locationErr.Error())
+	}
+	if len(problems) > 0 {
+		sort.Strings(problems)
+		err = errors.New("invalid config:\n  " + strings.Join(problems, "\n  "))
+	}
+	return
+}
+
+//validateSlack adds a problem for anything in the specified slack config (at the specified path in the config file)
+// that's invalid
+func validateSlack(slackConfig *SlackConfig, slackPath string, problem func(path, message string)) {
+	if slackConfig.WebhookID != "" && slackConfig.Token != "" {
+		problem(slackPath, "webhookID and token can't both be set")
+	}
+	if slackConfig.Token != "" && slackConfig.Channel == "" {
+		problem(slackPath+".channel", "required when token is set")
+	}
+	if slackConfig.WebhookID != "" && slackConfig.Channel != "" {
+		problem(slackPath+".channel", "only used with token, a webhook posts to its own channel")
+	}
+	if slackConfig.APIURL != "" && !strings.HasPrefix(slackConfig.APIURL, "https://") &&
+		!strings.HasPrefix(slackConfig.APIURL, "http://") {
+		problem(slackPath+".apiURL", "must be an http(s) url")
+	}
+	validateHTTP(&slackConfig.HTTP, slackPath+".http", problem)
+}
+
+//validateTeams adds a problem for anything in the specified teams config (at the specified path in the config file)
+// that's invalid
+func validateTeams(teamsConfig *TeamsConfig, teamsPath string, problem func(path, message string)) {
+	if teamsConfig.WebhookURL != "" && !strings.HasPrefix(teamsConfig.WebhookURL, "https://") &&
+		!strings.HasPrefix(teamsConfig.WebhookURL, "http://") {
+		problem(teamsPath+".webhookURL", "must be an http(s) url")
+	}
+	validateHTTP(&teamsConfig.HTTP, teamsPath+".http", problem)
+}
+
+//validateWebhook adds a problem for anything in the specified webhook config (at the specified path in the config
+// file) that's invalid, and otherwise parses its template
+func validateWebhook(webhookConfig *WebhookConfig, webhookPath string, problem func(path, message string)) {
+	if webhookConfig.URL != "" && !strings.HasPrefix(webhookConfig.URL, "https://") &&
+		!strings.HasPrefix(webhookConfig.URL, "http://") {
+		problem(webhookPath+".url", "must be an http(s) url")
+	}
+	if webhookConfig.Method != "" && !contains(validWebhookMethods, webhookConfig.Method) {
+		problem(webhookPath+".method", "must be one of "+strings.Join(validWebhookMethods, ", "))
+	}
+	var err error
+	webhookConfig.template, err = webhookTemplate(webhookConfig.Template)
+	if err != nil {
+		problem(webhookPath+".template", err.Error())
+	}
+	validateHTTP(&webhookConfig.HTTP, webhookPath+".http", problem)
+}
+
+//validateEmail adds a problem for anything in the specified email config (at the specified path in the config file)
+// that's invalid
+func validateEmail(emailConfig *EmailConfig, emailPath string, problem func(path, message string)) {
+	if emailConfig.Port < 0 || emailConfig.Port > 65535 {
+		problem(emailPath+".port", "must be a port number")
+	}
+	if emailConfig.StartTLS != "" && !contains(validStartTLS, emailConfig.StartTLS) {
+		problem(emailPath+".startTLS", "must be one of "+strings.Join(validStartTLS, ", "))
+	}
+	for i, to := range emailConfig.To {
+		if !strings.Contains(to, "@") {
+			problem(emailPath+".to["+strconv.Itoa(i)+"]", "must be an email address")
+		}
+	}
+}
+
+//validateAlertmanager adds a problem for anything in the specified alertmanager config (at the specified path in the
+// config file) that's invalid
+func validateAlertmanager(alertmanagerConfig *AlertmanagerConfig, alertmanagerPath string,
+	problem func(path, message string)) {
+
+	if alertmanagerConfig.URL != "" && !strings.HasPrefix(alertmanagerConfig.URL, "https://") &&
+		!strings.HasPrefix(alertmanagerConfig.URL, "http://") {
+		problem(alertmanagerPath+".url", "must be an http(s) url")
+	}
+	if alertmanagerConfig.ResolveTimeout != "" {
+		if timeout, err := time.ParseDuration(alertmanagerConfig.ResolveTimeout); err != nil || timeout <= 0 {
+			problem(alertmanagerPath+".resolveTimeout", "must be a positive duration, e.g. 15m")
+		}
+	}
+	for name := range alertmanagerConfig.Labels {
+		if !labelNameRegexp.MatchString(name) {
+			problem(alertmanagerPath+".labels."+name, "must be a valid label name")
+		}
+	}
+	validateHTTP(&alertmanagerConfig.HTTP, alertmanagerPath+".http", problem)
+}
+
+//validateGitHub adds a problem for anything in the specified GitHub config (at the specified path in the config file)
+// that's invalid
+func validateGitHub(githubConfig *GitHubConfig, githubPath string, problem func(path, message string)) {
+	if githubConfig.URL != "" && !strings.HasPrefix(githubConfig.URL, "https://") &&
+		!strings.HasPrefix(githubConfig.URL, "http://") {
+		problem(githubPath+".url", "must be an http(s) url")
+	}
+	if parts := strings.Split(githubConfig.Repo, "/"); githubConfig.Repo != "" &&
+		(len(parts) != 2 || parts[0] == "" || parts[1] == "") {
+		problem(githubPath+".repo", "must be [owner]/[name]")
+	}
+	validateHTTP(&githubConfig.HTTP, githubPath+".http", problem)
+}
+
+//validateGitLab adds a problem for anything in the specified GitLab config (at the specified path in the config file)
+// that's invalid
+func validateGitLab(gitlabConfig *GitLabConfig, gitlabPath string, problem func(path, message string)) {
+	if gitlabConfig.URL != "" && !strings.HasPrefix(gitlabConfig.URL, "https://") &&
+		!strings.HasPrefix(gitlabConfig.URL, "http://") {
+		problem(gitlabPath+".url", "must be an http(s) url")
+	}
+	for i, label := range gitlabConfig.Labels {
+		if label == "" || strings.Contains(label, ",") || strings.HasPrefix(label, gitlabIssueLabelPrefix) {
+			problem(gitlabPath+".labels["+strconv.Itoa(i)+"]", "must be non-empty, without commas, and not start "+
+				gitlabIssueLabelPrefix)
+		}
+	}
+	validateHTTP(&gitlabConfig.HTTP, gitlabPath+".http", problem)
+}
+
+//validateJira adds a problem for anything in the specified JIRA config (at the specified path in the config file)
+// that's invalid
+func validateJira(jiraConfig *JiraConfig, jiraPath string, problem func(path, message string)) {
+	if jiraConfig.ReopenTransition != "" && jiraConfig.CloseTransition == "" {
+		problem(jiraPath+".reopenTransition", "requires closeTransition, only issues inspectr closed are reopened")
+	}
+}
+
+//applyConfig makes the config's filters, registries and version policies the ones used by subsequent scans
+func applyConfig(config *Config) {
+	includeNamespaces = config.Filters.IncludeNamespaces
+	ignoreNamespaces = config.Filters.IgnoreNamespaces
+	podSelector = config.Filters.PodSelector
+	namespaceSelector = config.Filters.NamespaceSelector
+	ignoreTags = stringSet(config.Filters.IgnoreTags)
+	ignoreImages = config.Filters.IgnoreImages
+	allowedPodPhases = stringSet(config.Filters.AllowedPodPhases)
+	registryConfigs = config.Registries
+	versionPolicies = config.Policies
+}
+
+//stringSet returns a set containing each of the specified strings
+func stringSet(strs []string) (set map[string]struct{}) {
+	set = make(map[string]struct{}, len(strs))
+	for _, str := range strs {
+		set[str] = struct{}{}
+	}
+	return
+}
+
+//versionPolicy returns the first version policy whose images globs match the specified image, or nil if none do
+func versionPolicy(imageString string) (policy *VersionPolicy) {
+	for i, candidate := range versionPolicies {
+		for _, image := range candidate.Images {
+			if matched, _ := path.Match(image, imageString); matched {
+				policy = &versionPolicies[i]
+				return
+			}
+		}
+	}
+	return
+}
+
+//allows returns a bool indicating whether the policy lets an upgrade from the current version to the candidate one
+// be reported. A nil policy allows everything
+func (policy *VersionPolicy) allows(currentVersion, candidateVersion string) (allowed bool) {
+	allowed = true
+	if policy != nil {
+		if policy.IgnorePrereleases {
+			candidate, err := version.NewVersion(candidateVersion)
+			allowed = err == nil && candidate.Prerelease() == ""
+		}
+		if allowed && policy.tagRegexp != nil {
+			allowed = policy.tagRegexp.MatchString(candidateVersion)
+		}
+		if allowed && len(policy.UpgradeClasses) > 0 {
+			allowed = contains(policy.UpgradeClasses, upgradeClass(currentVersion, candidateVersion))
+		}
+	}
+	return
+}
+
+//registryConfig returns the registry the specified image is hosted on: the first configured registry whose host is
+// in the image, then the default registries, then dockerhub
+func registryConfig(imageString string) (registry RegistryConfig) {
+	registry = RegistryConfig{Host: "registry.hub.docker.com", API: "dockerhub"}
+	for _, candidate := range append(append([]RegistryConfig{}, registryConfigs...), defaultRegistries...) {
+		if strings.Contains(imageString, candidate.Host) {
+			registry = candidate
+			break
+		}
+	}
+	return
+}
@@ -0,0 +1,157 @@
+package main
+
+import (
+	"fmt"
+	"html/template"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/golang/glog"
+)
+
+//dashboardNamespace type representing the results shown in a single namespace's table on the dashboard
+type dashboardNamespace struct {
+	Name    string
+	Results []APIResult
+}
+
+//dashboardData type representing everything the dashboard template renders
+type dashboardData struct {
+	Scan       *Scan
+	Namespaces []dashboardNamespace
+}
+
+var dashboardTemplate = template.Must(template.New("dashboard").Funcs(template.FuncMap{
+	"date": func(t time.Time) string {
+		return t.Format("2006-01-02 15:04 MST")
+	},
+	"days": func(daysBehind int) string {
+		return daysBehindString(InspectrResult{DaysBehind: daysBehind})
+	},
+	"sortKey": sortKey,
+}).Parse(dashboardHTML))
+
+//handleDashboard serves a server-rendered page listing the latest scan's outdated images, one table per namespace.
+// It has no external assets so that it works without internet access
+func handleDashboard(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != "/" {
+		http.NotFound(w, r)
+		return
+	}
+	data := dashboardData{Scan: getLatestScan()}
+	if data.Scan != nil {
+		data.Namespaces = dashboardNamespaces(data.Scan.Results)
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	err := dashboardTemplate.Execute(w, data)
+	if err != nil {
+		glog.Error(err)
+	}
+}
+
+//dashboardNamespaces returns the specified results grouped by namespace, namespaces in alphabetical order
+func dashboardNamespaces(results []APIResult) (namespaces []dashboardNamespace) {
+	namespaceIndexes := make(map[string]int)
+	for _, result := range results {
+		index, ok := namespaceIndexes[result.Namespace]
+		if !ok {
+			index = len(namespaces)
+			namespaceIndexes[result.Namespace] = index
+			namespaces = append(namespaces, dashboardNamespace{Name: result.Namespace})
+		}
+		namespaces[index].Results = append(namespaces[index].Results, result)
+	}
+	sort.Slice(namespaces, func(i, j int) bool {
+		return namespaces[i].Name < namespaces[j].Name
+	})
+	return
+}
+
+//unknownSortKey is the sort key given to unknown (negative) values, which sorts them after every known value
+const unknownSortKey = "9999999999"
+
+//sortKey returns a zero-padded string so that the dashboard's table sorting orders numbers numerically,
+//with unknown values last
+func sortKey(n int) string {
+	if n < 0 {
+		return unknownSortKey
+	}
+	return fmt.Sprintf("%010d", n)
+}
+
+const dashboardHTML = `<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>inspectr</title>
+<style>
+body { font-family: sans-serif; margin: 2em; color: #222; }
+table { border-collapse: collapse; width: 100%; margin-bottom: 2em; }
+th, td { border-bottom: 1px solid #ddd; padding: 0.4em 0.6em; text-align: left; vertical-align: top; }
+th { background: #f4f4f4; cursor: pointer; user-select: none; }
+th:after { content: " \2195"; color: #aaa; }
+.badge { border-radius: 0.3em; padding: 0.1em 0.5em; color: #fff; font-size: 0.85em; }
+.major { background: #c0392b; }
+.minor { background: #e67e22; }
+.patch { background: #27ae60; }
+.unknown { background: #7f8c8d; }
+.meta, .seen { color: #666; font-size: 0.9em; }
+.errors { color: #c0392b; }
+</style>
+</head>
+<body>
+<h1>inspectr</h1>
+{{with .Scan}}
+<p class="meta">last scan: {{date .Start}} to {{date .End}}</p>
+{{if .Errors}}<div class="errors"><p>registry errors:</p><ul>
+{{range $registry, $errors := .Errors}}{{range $errors}}<li>{{$registry}}: {{.}}</li>{{end}}{{end}}
+</ul></div>{{end}}
+{{else}}
+<p>no scan has completed yet</p>
+{{end}}
+{{range .Namespaces}}
+<h2>{{.Name}}</h2>
+<table class="sortable">
+<thead><tr>
+<th>workload</th><th>container</th><th>image</th><th>current</th><th>latest</th><th>class</th>
+<th>versions behind</th><th>days behind</th><th>upgrades (first seen)</th><th>issue</th>
+</tr></thead>
+<tbody>
+{{range .Results}}{{$result := .}}<tr>
+<td>{{.Workload}}</td>
+<td>{{.Container}}</td>
+<td>{{.Image}}</td>
+<td>{{.Version}}</td>
+<td>{{.LatestVersion}}</td>
+<td><span class="badge {{.UpgradeClass}}">{{.UpgradeClass}}</span></td>
+<td data-sort="{{sortKey .VersionsBehind}}">{{.VersionsBehind}}</td>
+<td data-sort="{{sortKey .DaysBehind}}">{{days .DaysBehind}}</td>
+<td>{{range .Upgrades}}{{.}}{{with index $result.FirstSeen .}} <span class="seen">({{date .}})</span>{{end}}<br>{{end}}</td>
+<td>{{with .Issue}}<a href="{{.}}">{{.}}</a>{{end}}</td>
+</tr>{{end}}
+</tbody>
+</table>
+{{end}}
+<script>
+document.querySelectorAll("table.sortable th").forEach(function (th) {
+  th.addEventListener("click", function () {
+    var tbody = th.closest("table").tBodies[0];
+    var ascending = th.dataset.order !== "asc";
+    th.dataset.order = ascending ? "asc" : "desc";
+    var value = function (row) {
+      var cell = row.cells[th.cellIndex];
+      return cell.dataset.sort || cell.textContent.trim();
+    };
+    Array.prototype.slice.call(tbody.rows).sort(function (a, b) {
+      var order = value(a).localeCompare(value(b));
+      return ascending ? order : -order;
+    }).forEach(function (row) {
+      tbody.appendChild(row);
+    });
+  });
+});
+</script>
+</body>
+</html>
+`
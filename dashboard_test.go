@@ -0,0 +1,72 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestHandleDashboard(t *testing.T) {
+	setLatestScan(nil)
+	recorder := httptest.NewRecorder()
+	handleDashboard(recorder, httptest.NewRequest("GET", "/", nil))
+	if recorder.Code != http.StatusOK || !strings.Contains(recorder.Body.String(), "no scan has completed yet") {
+		t.Errorf("handleDashboard before any scan returned %d: %s", recorder.Code, recorder.Body.String())
+	}
+
+	mapKey := "project:cluster:eversc/banana:banana:banana"
+	upgradeMap := map[string][]InspectrResult{
+		mapKey: {{Name: "eversc/banana", Namespace: "banana-namespace", Version: "v1.0.0",
+			Upgrades: []string{"v2.0.0"}, LatestVersion: "v2.0.0", UpgradeClass: "major", DaysBehind: -1}},
+	}
+	updateUpgradeHistory(upgradeMap, upgradeMap, time.Date(2017, 6, 1, 10, 0, 0, 0, time.UTC))
+	setIssueURL(mapKey, "https://jira.example.com/browse/INS-1")
+	setLatestScan(newScan(time.Now(), time.Now(), upgradeMap, nil))
+	recorder = httptest.NewRecorder()
+	handleDashboard(recorder, httptest.NewRequest("GET", "/", nil))
+	body := recorder.Body.String()
+	for _, expected := range []string{"<h2>banana-namespace</h2>", `class="badge major"`,
+		"https://jira.example.com/browse/INS-1", "2017-06-01 10:00 UTC", ">?</td>"} {
+		if !strings.Contains(body, expected) {
+			t.Errorf("handleDashboard response didn't contain %s: %s", expected, body)
+		}
+	}
+
+	recorder = httptest.NewRecorder()
+	handleDashboard(recorder, httptest.NewRequest("GET", "/banana", nil))
+	if recorder.Code != http.StatusNotFound {
+		t.Errorf("handleDashboard(/banana) returned %d, expected %d", recorder.Code, http.StatusNotFound)
+	}
+}
+
+func TestUpdateUpgradeHistory(t *testing.T) {
+	mapKey := "project:cluster:eversc/apples:apples:apples"
+	first := time.Date(2017, 6, 1, 0, 0, 0, 0, time.UTC)
+	second := first.Add(24 * time.Hour)
+	result := InspectrResult{Namespace: "apples-namespace", Upgrades: []string{"v0.0.2"}}
+	updateUpgradeHistory(map[string][]InspectrResult{mapKey: {result}},
+		map[string][]InspectrResult{mapKey: {result}}, first)
+	result.Upgrades = []string{"v0.0.2", "v0.0.3"}
+	updateUpgradeHistory(map[string][]InspectrResult{mapKey: {result}},
+		map[string][]InspectrResult{mapKey: {result}}, second)
+	firstSeen := upgradesFirstSeen(mapKey, result)
+	if !firstSeen["v0.0.2"].Equal(first) || !firstSeen["v0.0.3"].Equal(second) {
+		t.Errorf("upgradesFirstSeen returned %v, expected v0.0.2 at %s and v0.0.3 at %s", firstSeen, first,
+			second)
+	}
+	updateUpgradeHistory(map[string][]InspectrResult{}, map[string][]InspectrResult{}, second)
+	if firstSeen := upgradesFirstSeen(mapKey, result); len(firstSeen) != 0 {
+		t.Errorf("upgradesFirstSeen returned %v after the workload went away, expected nothing", firstSeen)
+	}
+}
+
+func TestSortKey(t *testing.T) {
+	if sortKey(0) >= sortKey(9) || sortKey(9) >= sortKey(10) || sortKey(10) >= sortKey(1000000) {
+		t.Errorf("expected sort keys to order numerically, got %s, %s, %s, %s", sortKey(0), sortKey(9), sortKey(10), sortKey(1000000))
+	}
+	if sortKey(-1) <= sortKey(9000000) {
+		t.Errorf("expected unknown values to sort last, got %s for -1 and %s for 9000000", sortKey(-1), sortKey(9000000))
+	}
+}
@@ -1,7 +1,25 @@
 package main
 
+import "time"
+
 //List type
 type List struct {
 	Name string   `json:"name"`
 	Tags []string `json:"tags"`
 }
+
+//V2Manifest type representing the json schema of https://[registry]/v2/[image]/manifests/[tag], for either an image
+// manifest or a manifest list
+type V2Manifest struct {
+	Config struct {
+		Digest string `json:"digest"`
+	} `json:"config"`
+	Manifests []struct {
+		Digest string `json:"digest"`
+	} `json:"manifests"`
+}
+
+//V2ConfigBlob type representing the json schema of https://[registry]/v2/[image]/blobs/[config digest]
+type V2ConfigBlob struct {
+	Created time.Time `json:"created"`
+}
@@ -0,0 +1,228 @@
+package main
+
+import (
+	"bytes"
+	"crypto/tls"
+	"errors"
+	"html/template"
+	"io"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net"
+	"net/smtp"
+	"net/textproto"
+	"strconv"
+	"strings"
+	"time"
+)
+
+//defaultSMTPPort is the submission port, used unless another is configured
+const defaultSMTPPort = 587
+
+//validStartTLS are the ways STARTTLS can be used: if the server offers it (auto, the default), always (required), or
+// never (disabled)
+var validStartTLS = []string{"auto", "required", "disabled"}
+
+//emailHTMLTemplate renders the html part of the digest
+var emailHTMLTemplate = template.Must(template.New("email").Parse(`<html><body>
+<h2>{{.Header}}</h2>
+<p>{{.Summary}}</p>
+{{range .Groups}}<h3>{{.Name}}</h3>
+<table border="1" cellpadding="4" cellspacing="0">
+<tr><th>image</th><th>workload</th><th>current</th><th>new</th><th>versions behind</th><th>days behind</th><th>owner</th></tr>
+{{range .Images}}<tr><td><b>{{.Image}}</b> ({{.Class}})</td><td>{{.Workload}}</td><td>{{.Current}}</td><td>{{.New}}</td><td>{{.VersionsBehind}}</td><td>{{.DaysBehind}}</td><td>{{.Owner}}</td></tr>
+{{end}}</table>
+{{end}}</body></html>
+`))
+
+//EmailDigest type representing the results in an email, grouped by cluster and namespace
+type EmailDigest struct {
+	Header  string
+	Summary string
+	Groups  []EmailGroup
+}
+
+//EmailGroup type representing the images in a cluster and namespace(s)
+type EmailGroup struct {
+	Name   string
+	Images []EmailImage
+}
+
+//EmailImage type representing the results for an image, as they're shown in an email
+type EmailImage struct {
+	Image          string
+	Class          string
+	Workload       string
+	Current        string
+	New            string
+	VersionsBehind string
+	DaysBehind     string
+	Owner          string
+}
+
+//emailNotifier type: a Notifier that emails each full report as a digest. New findings and events aren't emailed,
+// they're in the next digest
+type emailNotifier struct {
+	config EmailConfig
+}
+
+//emailNotifier implementation of Notifier
+func (notifier *emailNotifier) FullReport(upgradeMap map[string][]InspectrResult) error {
+	digest := emailDigest(upgradeMap)
+	return sendEmail(notifier.config, digest.Header, digest.text(), digest.html())
+}
+
+//emailNotifier implementation of Notifier. New findings aren't emailed
+func (notifier *emailNotifier) NewFindings(upgradeMap map[string][]InspectrResult) error {
+	return nil
+}
+
+//emailNotifier implementation of Notifier. Events aren't emailed
+func (notifier *emailNotifier) Event(event NotifierEvent) error {
+	return nil
+}
+
+//emailDigest returns the specified results grouped by cluster and namespace, with the same details posted to slack
+func emailDigest(upgradeMap map[string][]InspectrResult) (digest EmailDigest) {
+	digest = EmailDigest{Header: slackHeader(upgradeMap, true), Summary: teamsSummary(upgradeMap)}
+	for _, k := range sortedSlackKeys(upgradeMap) {
+		v := upgradeMap[k]
+		name := projectFromInspectrMapKey(k) + "/" + clusterFromInspectrMapKey(k) + "  " +
+			slackFieldString(namespacesFromInspectrResults(v))
+		if len(digest.Groups) == 0 || digest.Groups[len(digest.Groups)-1].Name != name {
+			digest.Groups = append(digest.Groups, EmailGroup{Name: name})
+		}
+		group := &digest.Groups[len(digest.Groups)-1]
+		group.Images = append(group.Images, EmailImage{
+			Image:          imageFromInspectrMapKey(k),
+			Class:          mostSignificantUpgradeClass(v),
+			Workload:       podFromInspectrMapKey(k) + "/" + containerFromInspectrMapKey(k),
+			Current:        currentVersionStringFromInspectrResults(v),
+			New:            newVersionStringFromInspectrResults(v),
+			VersionsBehind: versionsBehindStringFromInspectrResults(v),
+			DaysBehind:     daysBehindStringFromInspectrResults(v),
+			Owner:          ownerStringFromInspectrResults(v),
+		})
+	}
+	return
+}
+
+//text returns the plain text part of the digest
+func (digest EmailDigest) text() string {
+	var buffer bytes.Buffer
+	buffer.WriteString(digest.Header + "\n" + digest.Summary + "\n")
+	for _, group := range digest.Groups {
+		buffer.WriteString("\n" + group.Name + "\n")
+		for _, image := range group.Images {
+			buffer.WriteString("\n  " + image.Image + " (" + image.Class + " upgrade)\n")
+			buffer.WriteString("    workload:        " + image.Workload + "\n")
+			buffer.WriteString("    current:         " + image.Current + "\n")
+			buffer.WriteString("    new:             " + image.New + "\n")
+			buffer.WriteString("    versions behind: " + image.VersionsBehind + "\n")
+			buffer.WriteString("    days behind:     " + image.DaysBehind + "\n")
+			if image.Owner != "" {
+				buffer.WriteString("    owner:           " + image.Owner + "\n")
+			}
+		}
+	}
+	return buffer.String()
+}
+
+//html returns the html part of the digest
+func (digest EmailDigest) html() string {
+	var buffer bytes.Buffer
+	emailHTMLTemplate.Execute(&buffer, digest)
+	return buffer.String()
+}
+
+//emailMessage returns a multipart/alternative message with the specified plain text and html parts
+func emailMessage(emailConfig EmailConfig, subject, text, html string, date time.Time) (msg []byte, err error) {
+	var body bytes.Buffer
+	writer := multipart.NewWriter(&body)
+	for _, part := range []struct {
+		contentType string
+		content     string
+	}{
+		{"text/plain; charset=utf-8", text},
+		{"text/html; charset=utf-8", html},
+	} {
+		var partWriter io.Writer
+		partWriter, err = writer.CreatePart(textproto.MIMEHeader{"Content-Type": {part.contentType},
+			"Content-Transfer-Encoding": {"quoted-printable"}})
+		if err != nil {
+			return
+		}
+		qpWriter := quotedprintable.NewWriter(partWriter)
+		qpWriter.Write([]byte(part.content))
+		qpWriter.Close()
+	}
+	writer.Close()
+	var buffer bytes.Buffer
+	buffer.WriteString("From: " + emailConfig.From + "\r\n")
+	buffer.WriteString("To: " + strings.Join(emailConfig.To, ", ") + "\r\n")
+	buffer.WriteString("Subject: " + subject + "\r\n")
+	buffer.WriteString("Date: " + date.Format(time.RFC1123Z) + "\r\n")
+	buffer.WriteString("MIME-Version: 1.0\r\n")
+	buffer.WriteString("Content-Type: multipart/alternative; boundary=" + writer.Boundary() + "\r\n\r\n")
+	buffer.Write(body.Bytes())
+	msg = buffer.Bytes()
+	return
+}
+
+//sendEmail sends the plain text and html to the config's recipients through its SMTP server, using STARTTLS as
+// configured, and authenticating if there's a username
+func sendEmail(emailConfig EmailConfig, subject, text, html string) (err error) {
+	var msg []byte
+	msg, err = emailMessage(emailConfig, subject, text, html, time.Now())
+	if err != nil {
+		return
+	}
+	port := emailConfig.Port
+	if port == 0 {
+		port = defaultSMTPPort
+	}
+	var conn net.Conn
+	conn, err = net.DialTimeout("tcp", net.JoinHostPort(emailConfig.Host, strconv.Itoa(port)), defaultHTTPTimeout)
+	if err != nil {
+		return
+	}
+	conn.SetDeadline(time.Now().Add(defaultHTTPTimeout))
+	var client *smtp.Client
+	client, err = smtp.NewClient(conn, emailConfig.Host)
+	if err != nil {
+		conn.Close()
+		return
+	}
+	defer client.Close()
+	startTLS := emailConfig.StartTLS
+	if ok, _ := client.Extension("STARTTLS"); ok && startTLS != "disabled" {
+		err = client.StartTLS(&tls.Config{ServerName: emailConfig.Host})
+	} else if startTLS == "required" {
+		err = errors.New("smtp server " + emailConfig.Host + " doesn't support STARTTLS")
+	}
+	if err == nil && emailConfig.Username != "" {
+		err = client.Auth(smtp.PlainAuth("", emailConfig.Username, emailConfig.Password, emailConfig.Host))
+	}
+	if err == nil {
+		err = client.Mail(emailConfig.From)
+	}
+	for _, to := range emailConfig.To {
+		if err == nil {
+			err = client.Rcpt(to)
+		}
+	}
+	if err == nil {
+		var dataWriter io.WriteCloser
+		dataWriter, err = client.Data()
+		if err == nil {
+			_, err = dataWriter.Write(msg)
+			if closeErr := dataWriter.Close(); err == nil {
+				err = closeErr
+			}
+		}
+	}
+	if err == nil {
+		err = client.Quit()
+	}
+	return
+}
@@ -0,0 +1,176 @@
+package main
+
+import (
+	"io/ioutil"
+	"mime"
+	"mime/multipart"
+	"net"
+	"net/mail"
+	"net/textproto"
+	"strings"
+	"testing"
+)
+
+//smtpSink type: a local SMTP server that accepts any message (and PLAIN auth), recording what it's sent. done is
+// closed once it's handled its connection
+type smtpSink struct {
+	listener net.Listener
+	done     chan struct{}
+	auth     string
+	from     string
+	to       []string
+	data     string
+}
+
+//newSMTPSink returns an smtpSink listening on a local port, which handles a single connection
+func newSMTPSink(t *testing.T) *smtpSink {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	sink := &smtpSink{listener: listener, done: make(chan struct{})}
+	go sink.serve()
+	return sink
+}
+
+func (sink *smtpSink) port() int {
+	return sink.listener.Addr().(*net.TCPAddr).Port
+}
+
+func (sink *smtpSink) serve() {
+	defer close(sink.done)
+	conn, err := sink.listener.Accept()
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+	text := textproto.NewConn(conn)
+	text.PrintfLine("220 localhost sink")
+	for {
+		line, err := text.ReadLine()
+		if err != nil {
+			return
+		}
+		command := strings.ToUpper(strings.SplitN(line, " ", 2)[0])
+		switch command {
+		case "EHLO":
+			text.PrintfLine("250-localhost\r\n250 AUTH PLAIN")
+		case "AUTH":
+			sink.auth = line
+			text.PrintfLine("235 ok")
+		case "MAIL":
+			sink.from = line
+			text.PrintfLine("250 ok")
+		case "RCPT":
+			sink.to = append(sink.to, line)
+			text.PrintfLine("250 ok")
+		case "DATA":
+			text.PrintfLine("354 go ahead")
+			data, _ := text.ReadDotBytes()
+			sink.data = string(data)
+			text.PrintfLine("250 ok")
+		case "QUIT":
+			text.PrintfLine("221 bye")
+			return
+		default:
+			text.PrintfLine("250 ok")
+		}
+	}
+}
+
+func TestEmailNotifier(t *testing.T) {
+	sink := newSMTPSink(t)
+	defer sink.listener.Close()
+	notifier := &emailNotifier{config: EmailConfig{Host: "127.0.0.1", Port: sink.port(), Username: "inspectr",
+		Password: "s3cret", From: "inspectr@example.com", To: []string{"a@example.com", "b@example.com"}}}
+	upgradeMap := map[string][]InspectrResult{
+		"project:prod:eversc/app:app:app": {{Namespace: "web", Version: "1.0.0", Upgrades: []string{"1.1.0"},
+			UpgradeClass: "minor", VersionsBehind: 1, DaysBehind: 3}},
+		"project:prod:eversc/db:db:db": {{Namespace: "data", Version: "1.0.0", Upgrades: []string{"2.0.0"},
+			UpgradeClass: "major", VersionsBehind: 4, Ownership: Ownership{Team: "storage"}}},
+	}
+	if err := notifier.FullReport(upgradeMap); err != nil {
+		t.Fatal(err)
+	}
+	<-sink.done
+	if sink.auth == "" || sink.from != "MAIL FROM:<inspectr@example.com>" || len(sink.to) != 2 {
+		t.Errorf("sink was sent auth %q, from %q, to %v", sink.auth, sink.from, sink.to)
+	}
+	msg, err := mail.ReadMessage(strings.NewReader(sink.data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v := msg.Header.Get("Subject"); v != "inspectr report: 2 images with upgrades available" {
+		t.Errorf("email has subject %s", v)
+	}
+	mediaType, params, _ := mime.ParseMediaType(msg.Header.Get("Content-Type"))
+	if mediaType != "multipart/alternative" {
+		t.Fatalf("email has content type %s", mediaType)
+	}
+	parts := make(map[string]string)
+	reader := multipart.NewReader(msg.Body, params["boundary"])
+	for {
+		part, err := reader.NextPart()
+		if err != nil {
+			break
+		}
+		content, _ := ioutil.ReadAll(part)
+		parts[strings.SplitN(part.Header.Get("Content-Type"), ";", 2)[0]] = string(content)
+	}
+	//data sorts before web, so the db image comes first
+	text := parts["text/plain"]
+	if !strings.Contains(text, "project/prod  data\n\n  eversc/db (major upgrade)") ||
+		!strings.Contains(text, "owner:           storage") {
+		t.Errorf("email has text part %s", text)
+	}
+	if html := parts["text/html"]; !strings.Contains(html, "<h3>project/prod  web</h3>") ||
+		!strings.Contains(html, "<td><b>eversc/app</b> (minor)</td>") {
+		t.Errorf("email has html part %s", html)
+	}
+}
+
+func TestEmailNotifierStartTLSRequired(t *testing.T) {
+	sink := newSMTPSink(t)
+	defer sink.listener.Close()
+	notifier := &emailNotifier{config: EmailConfig{Host: "127.0.0.1", Port: sink.port(), StartTLS: "required",
+		From: "inspectr@example.com", To: []string{"a@example.com"}}}
+	if err := notifier.FullReport(nil); err == nil || !strings.Contains(err.Error(), "doesn't support STARTTLS") {
+		t.Errorf("FullReport to a server without STARTTLS returned error %v", err)
+	}
+	<-sink.done
+	if sink.data != "" {
+		t.Errorf("email was sent without STARTTLS: %s", sink.data)
+	}
+}
+
+var invalidEmailConfigs = []struct {
+	yaml  string
+	error string
+}{
+	{"outputs:\n  email:\n    host: smtp.example.com\n    port: 70000", "outputs.email.from: required"},
+	{"outputs:\n  email:\n    host: smtp.example.com\n    port: 70000", "outputs.email.port: must be a port number"},
+	{"outputs:\n  email: {startTLS: always}", "outputs.email.startTLS: must be one of auto, required, disabled"},
+	{"receivers:\n  - name: a\n    email: {to: [payments]}", "receivers[0].email.to[0]: must be an email address"},
+	{"receivers:\n  - name: a\n    email: {to: [a@example.com]}", "receivers[0].email.host: required, or set it"},
+	{"receivers:\n  - name: a\n    email: {host: smtp.example.com}", "receivers[0].email.to: required"},
+}
+
+func TestValidateEmail(t *testing.T) {
+	for _, invalidEmailConfig := range invalidEmailConfigs {
+		if _, err := parseConfig([]byte(invalidEmailConfig.yaml), env{}.lookup); err == nil ||
+			!strings.Contains(err.Error(), invalidEmailConfig.error) {
+			t.Errorf("parseConfig(%s) returned error %v, expected it to contain %s", invalidEmailConfig.yaml, err,
+				invalidEmailConfig.error)
+		}
+	}
+	config, err := parseConfig([]byte("outputs:\n  email: {host: smtp.example.com, port: 25, from: i@example.com}\n"+
+		"receivers:\n  - name: payments\n    email: {to: [payments@example.com]}"), env{}.lookup)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v := config.receivers["payments"].Email; v == nil || v.Host != "smtp.example.com" || v.Port != 25 ||
+		v.From != "i@example.com" || config.receivers[defaultReceiver].Email != nil {
+		t.Errorf("payments receiver resolved to email %+v, default to %+v, expected only payments with "+
+			"outputs.email's server", v, config.receivers[defaultReceiver].Email)
+	}
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+//nextLinkRegexp matches the url of the next page in an api response's Link header (GitHub's and GitLab's)
+var nextLinkRegexp = regexp.MustCompile(`<([^>]+)>;\s*rel="next"`)
+
+//forgeClient type: a client for a code forge's json api, shared by the github and gitlab outputs, which differ only
+// in the api's url, the headers each request needs (e.g. for auth) and the name used in errors
+type forgeClient struct {
+	name    string
+	apiURL  string
+	headers map[string]string
+	http    HTTPConfig
+}
+
+//call calls the api with the method at the specified path (relative to the api's url, or a next page url returned by
+// a previous call), sending body (if it isn't nil) and decoding the response into out (if it isn't nil). It returns
+// the url of the next page of the response, if there is one. A next page on another host is an error rather than
+// followed, so the api's credentials are never sent anywhere else
+func (client forgeClient) call(method, path string, body, out interface{}) (next string, err error) {
+	callURL := path
+	if !strings.HasPrefix(path, "https://") && !strings.HasPrefix(path, "http://") {
+		callURL = client.apiURL + path
+	}
+	var reqBody bytes.Buffer
+	if body != nil {
+		err = json.NewEncoder(&reqBody).Encode(body)
+	}
+	var req *http.Request
+	if err == nil {
+		req, err = http.NewRequest(method, callURL, &reqBody)
+	}
+	if err == nil {
+		for name, value := range client.headers {
+			req.Header.Set(name, value)
+		}
+		if body != nil {
+			req.Header.Set("Content-Type", "application/json; charset=utf-8")
+		}
+		var resp *http.Response
+		resp, err = client.http.httpClient().Do(req)
+		if err == nil {
+			defer resp.Body.Close()
+			err = statusError(client.name+" "+method+" "+strings.SplitN(path, "?", 2)[0], resp)
+			if err == nil && out != nil {
+				err = json.NewDecoder(resp.Body).Decode(out)
+			}
+			if match := nextLinkRegexp.FindStringSubmatch(resp.Header.Get("Link")); match != nil && err == nil {
+				next = match[1]
+				if !client.onAPIHost(next) {
+					next = ""
+					err = errors.New(client.name + " " + method + " " + strings.SplitN(path, "?", 2)[0] +
+						" returned a next page on another host, not following it")
+				}
+			}
+		}
+		err = redactedURLError(client.name, err)
+	}
+	return
+}
+
+//onAPIHost returns a bool indicating whether the specified url has the same scheme and host as the api's url
+func (client forgeClient) onAPIHost(rawURL string) bool {
+	apiURL, err := url.Parse(client.apiURL)
+	if err != nil {
+		return false
+	}
+	parsedURL, err := url.Parse(rawURL)
+	return err == nil && parsedURL.Scheme == apiURL.Scheme && parsedURL.Host == apiURL.Host
+}
@@ -0,0 +1,37 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestForgeClientNextPage(t *testing.T) {
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("PRIVATE-TOKEN") != "s3cret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		switch r.URL.Query().Get("page") {
+		case "":
+			w.Header().Set("Link", "<"+server.URL+"/api/v4/issues?page=2>; rel=\"next\"")
+		case "2":
+			w.Header().Set("Link", "<https://elsewhere.example.com/api/v4/issues?page=3>; rel=\"next\"")
+		}
+		w.Write([]byte("[]"))
+	}))
+	defer server.Close()
+	client := forgeClient{name: "gitlab", apiURL: server.URL + "/api/v4/",
+		headers: map[string]string{"PRIVATE-TOKEN": "s3cret"}}
+	next, err := client.call("GET", "issues", nil, &[]GitLabIssue{})
+	if err != nil || next != server.URL+"/api/v4/issues?page=2" {
+		t.Fatalf("forgeClient.call returned next page %s, %v, expected page 2", next, err)
+	}
+	next, err = client.call("GET", next, nil, &[]GitLabIssue{})
+	if next != "" || err == nil || !strings.Contains(err.Error(), "next page on another host") {
+		t.Errorf("forgeClient.call returned next page %s, %v, expected an error rather than leaving the api's host",
+			next, err)
+	}
+}
@@ -2,12 +2,10 @@ package main
 
 //Gcr type representing the json schema of http://gcr.io/v2/[image]/tags/list
 type Gcr struct {
-	Child    []interface{} `json:"child"`
-	Manifest struct {
-		ImageMap map[string]Image
-	} `json:"manifest"`
-	Name string   `json:"name"`
-	Tags []string `json:"tags"`
+	Child    []interface{}    `json:"child"`
+	Manifest map[string]Image `json:"manifest"`
+	Name     string           `json:"name"`
+	Tags     []string         `json:"tags"`
 }
 
 //Image type representing the dynamic "sha256:[]":{} part of gcr.io tags list
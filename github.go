@@ -0,0 +1,202 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+//githubAPIURL is the GitHub api used unless another (e.g. GitHub Enterprise's, https://[host]/api/v3/) is configured
+const githubAPIURL = "https://api.github.com/"
+
+//githubDefaultLabels are applied to the issues inspectr creates, and used to find them, unless others are configured
+var githubDefaultLabels = []string{"inspectr"}
+
+//kinds of NotifierEvent raised by the github output
+const (
+	githubCreatedEvent   = "github-created"
+	githubCommentedEvent = "github-commented"
+)
+
+//GitHubIssue type representing the json schema of a GitHub issue
+type GitHubIssue struct {
+	Number      int             `json:"number"`
+	Title       string          `json:"title"`
+	Body        string          `json:"body"`
+	HTMLURL     string          `json:"html_url"`
+	PullRequest json.RawMessage `json:"pull_request,omitempty"`
+}
+
+//GitHubIssueRequest type representing the json schema of a request to create a GitHub issue
+type GitHubIssueRequest struct {
+	Title  string   `json:"title"`
+	Body   string   `json:"body"`
+	Labels []string `json:"labels,omitempty"`
+}
+
+//GitHubComment type representing the json schema of a comment on a GitHub issue
+type GitHubComment struct {
+	Body string `json:"body"`
+}
+
+//githubNotifier type: a Notifier that creates (or comments on) a GitHub issue for each image with upgrades, the same
+// way the JIRA output does
+type githubNotifier struct {
+	config GitHubConfig
+	events func(NotifierEvent)
+}
+
+//githubNotifier implementation of Notifier
+func (notifier *githubNotifier) FullReport(upgradeMap map[string][]InspectrResult) error {
+	return notifier.reportResults(upgradeMap)
+}
+
+//githubNotifier implementation of Notifier
+func (notifier *githubNotifier) NewFindings(upgradeMap map[string][]InspectrResult) error {
+	return notifier.reportResults(upgradeMap)
+}
+
+//githubNotifier implementation of Notifier. Events aren't reported to GitHub
+func (notifier *githubNotifier) Event(event NotifierEvent) error {
+	return nil
+}
+
+//reportResults comments on the open issue for each image in the upgradeMap with any results it doesn't mention yet, or
+// creates one if there isn't one. If an image can't be reported, the others still are, and the last error is returned
+func (notifier *githubNotifier) reportResults(upgradeMap map[string][]InspectrResult) (err error) {
+	if len(upgradeMap) == 0 {
+		return
+	}
+	var openIssues []GitHubIssue
+	openIssues, err = notifier.openIssues()
+	if err == nil {
+		issues := make(map[string]GitHubIssue)
+		for _, issue := range openIssues {
+			issues[issue.Title] = issue
+		}
+		for k, v := range upgradeMap {
+			var reportErr error
+			if issue, ok := issues[summaryFromInspectrMapKey(k)]; ok {
+				reportErr = notifier.commentOnIssue(issue, k, v)
+			} else {
+				reportErr = notifier.createIssue(k, v)
+			}
+			if reportErr != nil {
+				err = reportErr
+			}
+		}
+	}
+	return
+}
+
+//openIssues returns the repo's open issues with the configured labels (but not pull requests)
+func (notifier *githubNotifier) openIssues() (issues []GitHubIssue, err error) {
+	next := notifier.repoPath() + "/issues?state=open&per_page=100"
+	if labels := notifier.labels(); len(labels) > 0 {
+		next += "&labels=" + url.QueryEscape(strings.Join(labels, ","))
+	}
+	for next != "" && err == nil {
+		var page []GitHubIssue
+		next, err = notifier.client().call("GET", next, nil, &page)
+		for _, issue := range page {
+			if issue.PullRequest == nil {
+				issues = append(issues, issue)
+			}
+		}
+	}
+	return
+}
+
+//commentOnIssue comments on the issue with each of the results (for the image under the specified results map key)
+// that its body and comments don't mention yet, raising a githubCommentedEvent for each
+func (notifier *githubNotifier) commentOnIssue(issue GitHubIssue, mapKey string,
+	inspectrResults []InspectrResult) (err error) {
+
+	setIssueURL(mapKey, issue.HTMLURL)
+	mentions := []string{issue.Body}
+	next := notifier.repoPath() + "/issues/" + strconv.Itoa(issue.Number) + "/comments?per_page=100"
+	for next != "" && err == nil {
+		var page []GitHubComment
+		next, err = notifier.client().call("GET", next, nil, &page)
+		for _, comment := range page {
+			mentions = append(mentions, comment.Body)
+		}
+	}
+	for _, inspectrResult := range inspectrResults {
+		if err != nil {
+			break
+		}
+		mentioned := false
+		for _, mention := range mentions {
+			mentioned = mentioned || stringContainsInspectrResult(mention, inspectrResult)
+		}
+		if !mentioned {
+			_, err = notifier.client().call("POST", notifier.repoPath()+"/issues/"+strconv.Itoa(issue.Number)+"/comments",
+				GitHubComment{Body: markdownFromJira(commentFromInspectrResult(inspectrResult).Body)}, nil)
+			if err == nil {
+				notifier.events(NotifierEvent{"github", githubCommentedEvent, mapKey, issue.HTMLURL})
+			}
+		}
+	}
+	return
+}
+
+//createIssue creates an issue for the results (for the image under the specified results map key), with the
+// configured labels, and raises a githubCreatedEvent
+func (notifier *githubNotifier) createIssue(mapKey string, inspectrResults []InspectrResult) (err error) {
+	var buffer bytes.Buffer
+	buffer.WriteString(infraDetailsString(mapKey))
+	for _, inspectrResult := range inspectrResults {
+		buffer.WriteString(commentFromInspectrResult(inspectrResult).Body)
+		buffer.WriteString("\n\n")
+	}
+	var issue GitHubIssue
+	_, err = notifier.client().call("POST", notifier.repoPath()+"/issues", GitHubIssueRequest{
+		Title: summaryFromInspectrMapKey(mapKey), Body: markdownFromJira(buffer.String()), Labels: notifier.labels()},
+		&issue)
+	if err == nil {
+		setIssueURL(mapKey, issue.HTMLURL)
+		notifier.events(NotifierEvent{"github", githubCreatedEvent, mapKey, issue.HTMLURL})
+	}
+	return
+}
+
+//client returns a client for the configured GitHub api
+func (notifier *githubNotifier) client() forgeClient {
+	return forgeClient{name: "github", apiURL: notifier.apiURL(), http: notifier.config.HTTP, headers: map[string]string{
+		"Accept":        "application/vnd.github+json",
+		"Authorization": "Bearer " + notifier.config.Token,
+	}}
+}
+
+//apiURL returns the configured api url, or GitHub's, ending in "/"
+func (notifier *githubNotifier) apiURL() (apiURL string) {
+	apiURL = notifier.config.URL
+	if apiURL == "" {
+		apiURL = githubAPIURL
+	}
+	if !strings.HasSuffix(apiURL, "/") {
+		apiURL += "/"
+	}
+	return
+}
+
+//repoPath returns the api path of the configured repo
+func (notifier *githubNotifier) repoPath() string {
+	return "repos/" + notifier.config.Repo
+}
+
+//labels returns the configured labels, or githubDefaultLabels
+func (notifier *githubNotifier) labels() []string {
+	if notifier.config.Labels == nil {
+		return githubDefaultLabels
+	}
+	return notifier.config.Labels
+}
+
+//markdownFromJira returns the JIRA formatted text (see commentFromInspectrResult) as markdown
+func markdownFromJira(jiraText string) string {
+	return strings.Replace(jiraText, "{code}", "\n```\n", -1)
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+//fakeGitHub type: a local stand in for the GitHub (Enterprise) api, with a repo's issues and their comments
+type fakeGitHub struct {
+	url      string
+	issues   []GitHubIssue
+	labels   [][]string
+	comments map[string][]GitHubComment
+}
+
+func (github *fakeGitHub) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Header.Get("Authorization") != "Bearer ghp-test" {
+		w.WriteHeader(http.StatusUnauthorized)
+		w.Write([]byte(`{"message": "Bad credentials"}`))
+		return
+	}
+	path := strings.TrimPrefix(r.URL.Path, "/api/v3/repos/eversc/infra/issues")
+	switch {
+	case r.Method == "GET" && path == "" && r.URL.Query().Get("labels") != "inspectr":
+		w.Write([]byte("[]"))
+	case r.Method == "GET" && path == "" && r.URL.Query().Get("page") == "":
+		//the first page is a pull request, which isn't an issue
+		w.Header().Set("Link", "<"+github.url+"/api/v3/repos/eversc/infra/issues?labels=inspectr&page=2>; rel=\"next\"")
+		json.NewEncoder(w).Encode([]GitHubIssue{{Number: 99, Title: github.issues[0].Title,
+			PullRequest: json.RawMessage(`{}`)}})
+	case r.Method == "GET" && path == "":
+		json.NewEncoder(w).Encode(github.issues)
+	case r.Method == "POST" && path == "":
+		var request GitHubIssueRequest
+		json.NewDecoder(r.Body).Decode(&request)
+		issue := GitHubIssue{Number: len(github.issues) + 1, Title: request.Title, Body: request.Body,
+			HTMLURL: "https://github.example.com/eversc/infra/issues/" + strconv.Itoa(len(github.issues)+1)}
+		github.issues = append(github.issues, issue)
+		github.labels = append(github.labels, request.Labels)
+		json.NewEncoder(w).Encode(issue)
+	case r.Method == "GET" && strings.HasSuffix(path, "/comments"):
+		json.NewEncoder(w).Encode(github.comments[path])
+	case r.Method == "POST" && strings.HasSuffix(path, "/comments"):
+		var comment GitHubComment
+		json.NewDecoder(r.Body).Decode(&comment)
+		github.comments[path] = append(github.comments[path], comment)
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte("{}"))
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+func TestGitHubNotifier(t *testing.T) {
+	appKey := "project:github:eversc/app:app:app"
+	dbKey := "project:github:eversc/db:db:db"
+	github := &fakeGitHub{issues: []GitHubIssue{{Number: 1, Title: summaryFromInspectrMapKey(appKey),
+		HTMLURL: "https://github.example.com/eversc/infra/issues/1"}}, comments: make(map[string][]GitHubComment)}
+	server := httptest.NewServer(github)
+	defer server.Close()
+	github.url = server.URL
+	var events []NotifierEvent
+	notifier := &githubNotifier{config: GitHubConfig{URL: server.URL + "/api/v3", Token: "ghp-test",
+		Repo: "eversc/infra"}, events: func(event NotifierEvent) { events = append(events, event) }}
+	upgradeMap := map[string][]InspectrResult{
+		appKey: {{Name: "app", Namespace: "web", Version: "1.0.0", Upgrades: []string{"1.1.0"}}},
+		dbKey:  {{Name: "db", Namespace: "data", Version: "1.0.0", Upgrades: []string{"2.0.0"}}},
+	}
+	if err := notifier.NewFindings(upgradeMap); err != nil {
+		t.Fatal(err)
+	}
+	appComments := github.comments["/1/comments"]
+	if len(appComments) != 1 || !strings.Contains(appComments[0].Body, "```\nName: app\nNamespace: web") {
+		t.Errorf("app issue has comments %+v", appComments)
+	}
+	if len(github.issues) != 2 || github.issues[1].Title != summaryFromInspectrMapKey(dbKey) ||
+		!strings.HasPrefix(github.issues[1].Body, "project: project\nimage: eversc/db") ||
+		strings.Join(github.labels[0], ",") != "inspectr" {
+		t.Errorf("github has issues %+v, with labels %v", github.issues, github.labels)
+	}
+	if len(events) != 2 {
+		t.Errorf("notifier raised events %+v, expected a comment and a create", events)
+	}
+	//nothing new, so nothing is commented on or created
+	if err := notifier.FullReport(upgradeMap); err != nil || len(github.comments["/1/comments"]) != 1 ||
+		len(github.comments["/2/comments"]) != 0 || len(github.issues) != 2 {
+		t.Errorf("FullReport of the same results returned error %v, left issues %+v and comments %+v", err,
+			github.issues, github.comments)
+	}
+	notifier.config.Token = "ghp-wrong"
+	if err := notifier.NewFindings(upgradeMap); err == nil || err.Error() !=
+		`github GET repos/eversc/infra/issues returned status 401: {"message": "Bad credentials"}` {
+		t.Errorf("NewFindings with the wrong token returned error %v", err)
+	}
+}
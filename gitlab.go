@@ -0,0 +1,207 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+//gitlabAPIURL is the GitLab api used unless another (e.g. a self-hosted GitLab's, https://[host]/api/v4/) is
+// configured
+const gitlabAPIURL = "https://gitlab.com/api/v4/"
+
+//gitlabIssueLabelPrefix starts the label identifying the issue for an inspectr map key, see gitlabIssueLabel
+const gitlabIssueLabelPrefix = "inspectr-id:"
+
+//kinds of NotifierEvent raised by the gitlab output
+const (
+	gitlabCreatedEvent   = "gitlab-created"
+	gitlabCommentedEvent = "gitlab-commented"
+)
+
+//GitLabIssue type representing the json schema of a GitLab issue
+type GitLabIssue struct {
+	IID         int      `json:"iid"`
+	Title       string   `json:"title"`
+	Description string   `json:"description"`
+	Labels      []string `json:"labels"`
+	WebURL      string   `json:"web_url"`
+}
+
+//GitLabIssueRequest type representing the json schema of a request to create a GitLab issue. Labels are comma
+// separated
+type GitLabIssueRequest struct {
+	Title       string `json:"title"`
+	Description string `json:"description"`
+	Labels      string `json:"labels"`
+}
+
+//GitLabNote type representing the json schema of a note (comment) on a GitLab issue
+type GitLabNote struct {
+	Body string `json:"body"`
+}
+
+//gitlabNotifier type: a Notifier that creates (or comments on) a GitLab issue for each image with upgrades, found by
+// its gitlabIssueLabel rather than by searching summaries the way the JIRA output does
+type gitlabNotifier struct {
+	config GitLabConfig
+	events func(NotifierEvent)
+}
+
+//gitlabNotifier implementation of Notifier
+func (notifier *gitlabNotifier) FullReport(upgradeMap map[string][]InspectrResult) error {
+	return notifier.reportResults(upgradeMap)
+}
+
+//gitlabNotifier implementation of Notifier
+func (notifier *gitlabNotifier) NewFindings(upgradeMap map[string][]InspectrResult) error {
+	return notifier.reportResults(upgradeMap)
+}
+
+//gitlabNotifier implementation of Notifier. Events aren't reported to GitLab
+func (notifier *gitlabNotifier) Event(event NotifierEvent) error {
+	return nil
+}
+
+//gitlabIssueLabel returns the label identifying the issue for the specified inspectr map key: gitlabIssueLabelPrefix
+// followed by the start of the key's hex encoded sha256 (the key itself can be too long for a label)
+func gitlabIssueLabel(mapKey string) string {
+	sum := sha256.Sum256([]byte(mapKey))
+	return gitlabIssueLabelPrefix + hex.EncodeToString(sum[:])[:12]
+}
+
+//reportResults comments on the open issue for each image in the upgradeMap with any results it doesn't mention yet, or
+// creates one if there isn't one. If an image can't be reported, the others still are, and the last error is returned
+func (notifier *gitlabNotifier) reportResults(upgradeMap map[string][]InspectrResult) (err error) {
+	if len(upgradeMap) == 0 {
+		return
+	}
+	var openIssues []GitLabIssue
+	openIssues, err = notifier.openIssues()
+	if err == nil {
+		issues := make(map[string]GitLabIssue)
+		for _, issue := range openIssues {
+			for _, label := range issue.Labels {
+				if strings.HasPrefix(label, gitlabIssueLabelPrefix) {
+					issues[label] = issue
+				}
+			}
+		}
+		for k, v := range upgradeMap {
+			var reportErr error
+			if issue, ok := issues[gitlabIssueLabel(k)]; ok {
+				reportErr = notifier.commentOnIssue(issue, k, v)
+			} else {
+				reportErr = notifier.createIssue(k, v)
+			}
+			if reportErr != nil {
+				err = reportErr
+			}
+		}
+	}
+	return
+}
+
+//openIssues returns the project's open issues with the configured labels
+func (notifier *gitlabNotifier) openIssues() (issues []GitLabIssue, err error) {
+	next := notifier.projectPath() + "/issues?state=opened&per_page=100"
+	if labels := notifier.labels(); len(labels) > 0 {
+		next += "&labels=" + url.QueryEscape(strings.Join(labels, ","))
+	}
+	for next != "" && err == nil {
+		var page []GitLabIssue
+		next, err = notifier.client().call("GET", next, nil, &page)
+		issues = append(issues, page...)
+	}
+	return
+}
+
+//commentOnIssue adds a note to the issue for each of the results (for the image under the specified results map key)
+// that its description and notes don't mention yet, raising a gitlabCommentedEvent for each
+func (notifier *gitlabNotifier) commentOnIssue(issue GitLabIssue, mapKey string,
+	inspectrResults []InspectrResult) (err error) {
+
+	setIssueURL(mapKey, issue.WebURL)
+	notesPath := notifier.projectPath() + "/issues/" + strconv.Itoa(issue.IID) + "/notes"
+	mentions := []string{issue.Description}
+	next := notesPath + "?per_page=100"
+	for next != "" && err == nil {
+		var page []GitLabNote
+		next, err = notifier.client().call("GET", next, nil, &page)
+		for _, note := range page {
+			mentions = append(mentions, note.Body)
+		}
+	}
+	for _, inspectrResult := range inspectrResults {
+		if err != nil {
+			break
+		}
+		mentioned := false
+		for _, mention := range mentions {
+			mentioned = mentioned || stringContainsInspectrResult(mention, inspectrResult)
+		}
+		if !mentioned {
+			_, err = notifier.client().call("POST", notesPath,
+				GitLabNote{Body: markdownFromJira(commentFromInspectrResult(inspectrResult).Body)}, nil)
+			if err == nil {
+				notifier.events(NotifierEvent{"gitlab", gitlabCommentedEvent, mapKey, issue.WebURL})
+			}
+		}
+	}
+	return
+}
+
+//createIssue creates an issue for the results (for the image under the specified results map key), with the
+// configured labels and its gitlabIssueLabel, and raises a gitlabCreatedEvent
+func (notifier *gitlabNotifier) createIssue(mapKey string, inspectrResults []InspectrResult) (err error) {
+	var buffer bytes.Buffer
+	buffer.WriteString(infraDetailsString(mapKey))
+	for _, inspectrResult := range inspectrResults {
+		buffer.WriteString(commentFromInspectrResult(inspectrResult).Body)
+		buffer.WriteString("\n\n")
+	}
+	labels := append(append([]string{}, notifier.labels()...), gitlabIssueLabel(mapKey))
+	var issue GitLabIssue
+	_, err = notifier.client().call("POST", notifier.projectPath()+"/issues", GitLabIssueRequest{
+		Title: summaryFromInspectrMapKey(mapKey), Description: markdownFromJira(buffer.String()),
+		Labels: strings.Join(labels, ",")}, &issue)
+	if err == nil {
+		setIssueURL(mapKey, issue.WebURL)
+		notifier.events(NotifierEvent{"gitlab", gitlabCreatedEvent, mapKey, issue.WebURL})
+	}
+	return
+}
+
+//client returns a client for the configured GitLab api
+func (notifier *gitlabNotifier) client() forgeClient {
+	return forgeClient{name: "gitlab", apiURL: notifier.apiURL(), http: notifier.config.HTTP,
+		headers: map[string]string{"PRIVATE-TOKEN": notifier.config.Token}}
+}
+
+//apiURL returns the configured api url, or gitlab.com's, ending in "/"
+func (notifier *gitlabNotifier) apiURL() (apiURL string) {
+	apiURL = notifier.config.URL
+	if apiURL == "" {
+		apiURL = gitlabAPIURL
+	}
+	if !strings.HasSuffix(apiURL, "/") {
+		apiURL += "/"
+	}
+	return
+}
+
+//projectPath returns the api path of the configured project, which can be its id or its path (e.g. "group/name")
+func (notifier *gitlabNotifier) projectPath() string {
+	return "projects/" + url.PathEscape(notifier.config.Project)
+}
+
+//labels returns the configured labels, or the default ones (the same as GitHub's)
+func (notifier *gitlabNotifier) labels() []string {
+	if notifier.config.Labels == nil {
+		return githubDefaultLabels
+	}
+	return notifier.config.Labels
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+//fakeGitLab type: a local stand in for a self-hosted GitLab's api, with a project's issues and their notes
+type fakeGitLab struct {
+	issues []GitLabIssue
+	notes  map[int][]GitLabNote
+}
+
+func (gitlab *fakeGitLab) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Header.Get("PRIVATE-TOKEN") != "glpat-test" {
+		w.WriteHeader(http.StatusUnauthorized)
+		w.Write([]byte(`{"message":"401 Unauthorized"}`))
+		return
+	}
+	path := strings.TrimPrefix(r.URL.EscapedPath(), "/api/v4/projects/platform%2Finfra/issues")
+	parts := strings.Split(strings.Trim(path, "/"), "/")
+	switch {
+	case r.Method == "GET" && path == "":
+		var issues []GitLabIssue
+		for _, issue := range gitlab.issues {
+			if contains(issue.Labels, r.URL.Query().Get("labels")) {
+				issues = append(issues, issue)
+			}
+		}
+		json.NewEncoder(w).Encode(issues)
+	case r.Method == "POST" && path == "":
+		var request GitLabIssueRequest
+		json.NewDecoder(r.Body).Decode(&request)
+		issue := GitLabIssue{IID: len(gitlab.issues) + 1, Title: request.Title, Description: request.Description,
+			Labels: strings.Split(request.Labels, ","),
+			WebURL: "https://gitlab.example.com/platform/infra/-/issues/" + strconv.Itoa(len(gitlab.issues)+1)}
+		gitlab.issues = append(gitlab.issues, issue)
+		json.NewEncoder(w).Encode(issue)
+	case len(parts) == 2 && parts[1] == "notes":
+		iid, _ := strconv.Atoi(parts[0])
+		if r.Method == "POST" {
+			var note GitLabNote
+			json.NewDecoder(r.Body).Decode(&note)
+			gitlab.notes[iid] = append(gitlab.notes[iid], note)
+			w.WriteHeader(http.StatusCreated)
+		}
+		json.NewEncoder(w).Encode(gitlab.notes[iid])
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+func TestGitLabNotifier(t *testing.T) {
+	appKey := "project:gitlab:eversc/app:app:app"
+	dbKey := "project:gitlab:eversc/db:db:db"
+	//the app issue has been renamed, but still has its label
+	gitlab := &fakeGitLab{issues: []GitLabIssue{{IID: 1, Title: "upgrade the app",
+		Labels: []string{"inspectr", gitlabIssueLabel(appKey)}, WebURL: "https://gitlab.example.com/1"}},
+		notes: make(map[int][]GitLabNote)}
+	server := httptest.NewServer(gitlab)
+	defer server.Close()
+	var events []NotifierEvent
+	notifier := &gitlabNotifier{config: GitLabConfig{URL: server.URL + "/api/v4/", Token: "glpat-test",
+		Project: "platform/infra"}, events: func(event NotifierEvent) { events = append(events, event) }}
+	upgradeMap := map[string][]InspectrResult{
+		appKey: {{Name: "app", Namespace: "web", Version: "1.0.0", Upgrades: []string{"1.1.0"}}},
+		dbKey:  {{Name: "db", Namespace: "data", Version: "1.0.0", Upgrades: []string{"2.0.0"}}},
+	}
+	if err := notifier.NewFindings(upgradeMap); err != nil {
+		t.Fatal(err)
+	}
+	if len(gitlab.notes[1]) != 1 || !strings.Contains(gitlab.notes[1][0].Body, "Name: app\nNamespace: web") {
+		t.Errorf("app issue has notes %+v", gitlab.notes[1])
+	}
+	if len(gitlab.issues) != 2 || !contains(gitlab.issues[1].Labels, gitlabIssueLabel(dbKey)) ||
+		!contains(gitlab.issues[1].Labels, "inspectr") || gitlab.issues[1].Title != summaryFromInspectrMapKey(dbKey) {
+		t.Errorf("gitlab has issues %+v", gitlab.issues)
+	}
+	if len(events) != 2 {
+		t.Errorf("notifier raised events %+v, expected a comment and a create", events)
+	}
+	upgradeMap[dbKey] = append(upgradeMap[dbKey], InspectrResult{Name: "db", Namespace: "data", Version: "1.0.0",
+		Upgrades: []string{"2.0.0", "2.1.0"}})
+	if err := notifier.FullReport(upgradeMap); err != nil || len(gitlab.notes[1]) != 1 || len(gitlab.notes[2]) != 1 ||
+		len(gitlab.issues) != 2 {
+		t.Errorf("FullReport with a new db upgrade returned error %v, left issues %+v and notes %+v", err,
+			gitlab.issues, gitlab.notes)
+	}
+	if v := gitlabIssueLabel(appKey); v == gitlabIssueLabel(dbKey) || len(v) != len("inspectr-id:")+12 {
+		t.Errorf("gitlabIssueLabel returned %s", v)
+	}
+	notifier.config.Token = "glpat-wrong"
+	if err := notifier.NewFindings(upgradeMap); err == nil || err.Error() !=
+		`gitlab GET projects/platform%2Finfra/issues returned status 401: {"message":"401 Unauthorized"}` {
+		t.Errorf("NewFindings with the wrong token returned error %v", err)
+	}
+}
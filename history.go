@@ -0,0 +1,81 @@
+package main
+
+import (
+	"sync"
+	"time"
+)
+
+var (
+	//upgradeHistory records when each upgrade was first seen, keyed by inspectr map key then
+	// upgradeHistoryKey
+	upgradeHistory = make(map[string]map[string]time.Time)
+	//issueURLs records the JIRA (or GitHub) issue inspectr has created or updated for each inspectr map key
+	issueURLs    = make(map[string]string)
+	historyMutex sync.RWMutex
+)
+
+//updateUpgradeHistory records the specified time against any upgrades in upgradeMap that haven't been seen before.
+// History is forgotten for map keys that no longer appear in the scanned resultsMap, i.e. workloads that have gone
+func updateUpgradeHistory(resultsMap, upgradeMap map[string][]InspectrResult, now time.Time) {
+	historyMutex.Lock()
+	defer historyMutex.Unlock()
+	for k := range upgradeHistory {
+		if _, ok := resultsMap[k]; !ok {
+			delete(upgradeHistory, k)
+		}
+	}
+	for k, v := range upgradeMap {
+		firstSeen, ok := upgradeHistory[k]
+		if !ok {
+			firstSeen = make(map[string]time.Time)
+			upgradeHistory[k] = firstSeen
+		}
+		for _, result := range v {
+			for _, upgrade := range result.Upgrades {
+				historyKey := upgradeHistoryKey(result.Namespace, upgrade)
+				if _, ok := firstSeen[historyKey]; !ok {
+					firstSeen[historyKey] = now
+				}
+			}
+		}
+	}
+}
+
+//upgradeHistoryKey returns a string consisting of [namespace]|[upgrade version]
+func upgradeHistoryKey(namespace, upgrade string) string {
+	return namespace + "|" + upgrade
+}
+
+//upgradesFirstSeen returns when each of the InspectrResult's upgrades was first seen, keyed by upgrade version
+func upgradesFirstSeen(mapKey string, result InspectrResult) (firstSeen map[string]time.Time) {
+	historyMutex.RLock()
+	defer historyMutex.RUnlock()
+	firstSeen = make(map[string]time.Time)
+	for _, upgrade := range result.Upgrades {
+		if seen, ok := upgradeHistory[mapKey][upgradeHistoryKey(result.Namespace, upgrade)]; ok {
+			firstSeen[upgrade] = seen
+		}
+	}
+	return
+}
+
+//setIssueURL records the URL of the JIRA (or GitHub) issue tracking the specified inspectr map key
+func setIssueURL(mapKey, issueURL string) {
+	historyMutex.Lock()
+	defer historyMutex.Unlock()
+	issueURLs[mapKey] = issueURL
+}
+
+//clearIssueURL forgets the issue tracking the specified inspectr map key, e.g. because it's been closed
+func clearIssueURL(mapKey string) {
+	historyMutex.Lock()
+	defer historyMutex.Unlock()
+	delete(issueURLs, mapKey)
+}
+
+//issueURL returns the URL of the JIRA (or GitHub) issue tracking the specified inspectr map key, or "" if there isn't one
+func issueURL(mapKey string) string {
+	historyMutex.RLock()
+	defer historyMutex.RUnlock()
+	return issueURLs[mapKey]
+}
@@ -0,0 +1,127 @@
+package main
+
+import (
+	"bytes"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+//defaultHTTPTimeout is how long an output's requests can take, unless configured otherwise
+const defaultHTTPTimeout = 30 * time.Second
+
+//HTTPConfig type representing how an output's HTTP client connects. Timeout is a duration, e.g. "10s", ProxyURL is
+// used instead of the HTTPS_PROXY/HTTP_PROXY env vars, and the CAs in CAFile are trusted as well as the system's
+type HTTPConfig struct {
+	Timeout  string `yaml:"timeout"`
+	ProxyURL string `yaml:"proxyURL"`
+	CAFile   string `yaml:"caFile"`
+
+	client *http.Client
+}
+
+//isZero returns a bool indicating whether nothing is set in the HTTPConfig
+func (httpConfig *HTTPConfig) isZero() bool {
+	return httpConfig.Timeout == "" && httpConfig.ProxyURL == "" && httpConfig.CAFile == ""
+}
+
+//httpClient returns the client built when the config was validated, or one with the default timeout if it wasn't
+func (httpConfig *HTTPConfig) httpClient() *http.Client {
+	if httpConfig.client == nil {
+		return &http.Client{Timeout: defaultHTTPTimeout}
+	}
+	return httpConfig.client
+}
+
+//validateHTTP adds a problem for anything in the specified HTTP config (at the specified path in the config file)
+// that's invalid, and otherwise builds its client
+func validateHTTP(httpConfig *HTTPConfig, httpPath string, problem func(path, message string)) {
+	timeout := defaultHTTPTimeout
+	var err error
+	if httpConfig.Timeout != "" {
+		timeout, err = time.ParseDuration(httpConfig.Timeout)
+		if err != nil || timeout <= 0 {
+			problem(httpPath+".timeout", "must be a positive duration, e.g. 30s")
+		}
+	}
+	transport := &http.Transport{Proxy: http.ProxyFromEnvironment}
+	if httpConfig.ProxyURL != "" {
+		var proxyURL *url.URL
+		proxyURL, err = url.Parse(httpConfig.ProxyURL)
+		if err != nil || proxyURL.Host == "" {
+			problem(httpPath+".proxyURL", "must be a url, e.g. http://proxy.example.com:3128")
+		} else {
+			transport.Proxy = http.ProxyURL(proxyURL)
+		}
+	}
+	if httpConfig.CAFile != "" {
+		var caCertPool *x509.CertPool
+		caCertPool, err = certPoolWithCAFile(httpConfig.CAFile)
+		if err != nil {
+			problem(httpPath+".caFile", err.Error())
+		} else {
+			transport.TLSClientConfig = &tls.Config{RootCAs: caCertPool}
+		}
+	}
+	httpConfig.client = &http.Client{Transport: transport, Timeout: timeout}
+}
+
+//certPoolWithCAFile returns the system's CAs along with the ones in the specified PEM file
+func certPoolWithCAFile(caFile string) (caCertPool *x509.CertPool, err error) {
+	var caCert []byte
+	caCert, err = ioutil.ReadFile(caFile)
+	if err == nil {
+		caCertPool, _ = x509.SystemCertPool()
+		if caCertPool == nil {
+			caCertPool = x509.NewCertPool()
+		}
+		if !caCertPool.AppendCertsFromPEM(caCert) {
+			err = errors.New("no PEM certificates found in " + caFile)
+		}
+	}
+	return
+}
+
+//redactedURLError returns the error with the url it's for left out, if it's a *url.Error, so that secrets in urls
+// (e.g. webhooks) don't end up in logs
+func redactedURLError(what string, err error) error {
+	if urlErr, ok := err.(*url.Error); ok {
+		err = errors.New(what + ": " + strings.ToLower(urlErr.Op) + ": " + urlErr.Err.Error())
+	}
+	return err
+}
+
+//postJSON posts the payload, json encoded, to the url with the specified client, returning an error if the response
+// doesn't have a 2xx status. Errors start with what's being posted to, and don't include the url, which may be secret
+func postJSON(client *http.Client, postURL, what string, payload interface{}) (err error) {
+	bytesBuff := new(bytes.Buffer)
+	err = json.NewEncoder(bytesBuff).Encode(payload)
+	if err == nil {
+		var resp *http.Response
+		resp, err = client.Post(postURL, "application/json; charset=utf-8", bytesBuff)
+		if err == nil {
+			defer resp.Body.Close()
+			err = statusError(what, resp)
+		}
+		err = redactedURLError(what, err)
+	}
+	return
+}
+
+//statusError returns an error with the response's status and the start of its body if the status isn't 2xx, or nil
+func statusError(what string, resp *http.Response) (err error) {
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		body, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 512))
+		err = errors.New(what + " returned status " + strconv.Itoa(resp.StatusCode) + ": " +
+			strings.TrimSpace(string(body)))
+	}
+	return
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"encoding/pem"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+var httpConfigs = []struct {
+	httpConfig HTTPConfig
+	problem    string
+}{
+	{HTTPConfig{}, ""},
+	{HTTPConfig{Timeout: "10s", ProxyURL: "http://proxy.example.com:3128"}, ""},
+	{HTTPConfig{Timeout: "ten seconds"}, "http.timeout: must be a positive duration"},
+	{HTTPConfig{Timeout: "-1s"}, "http.timeout: must be a positive duration"},
+	{HTTPConfig{ProxyURL: "proxy"}, "http.proxyURL: must be a url"},
+	{HTTPConfig{CAFile: "/nonexistent/ca.crt"}, "http.caFile: open /nonexistent/ca.crt"},
+}
+
+func TestValidateHTTP(t *testing.T) {
+	for _, httpConfig := range httpConfigs {
+		var problems []string
+		validateHTTP(&httpConfig.httpConfig, "http", func(path, message string) {
+			problems = append(problems, path+": "+message)
+		})
+		if (httpConfig.problem == "" && len(problems) > 0) || (httpConfig.problem != "" &&
+			(len(problems) != 1 || !strings.HasPrefix(problems[0], httpConfig.problem))) {
+			t.Errorf("validateHTTP(%+v) found problems %v, expected %s", httpConfig.httpConfig, problems,
+				httpConfig.problem)
+		}
+	}
+}
+
+func TestHTTPConfigCAFile(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+	dir, err := ioutil.TempDir("", "inspectr")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	caFile := filepath.Join(dir, "ca.crt")
+	caCert := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw})
+	if err = ioutil.WriteFile(caFile, caCert, 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = (&HTTPConfig{}).httpClient().Get(server.URL); err == nil {
+		t.Error("request to a server with an untrusted certificate succeeded")
+	}
+	httpConfig := HTTPConfig{CAFile: caFile}
+	validateHTTP(&httpConfig, "http", func(path, message string) { t.Errorf("%s: %s", path, message) })
+	if _, err = httpConfig.httpClient().Get(server.URL); err != nil {
+		t.Errorf("request trusting the server's certificate returned error %v", err)
+	}
+}
@@ -2,8 +2,6 @@ package main
 
 import (
 	"bytes"
-	"crypto/tls"
-	"crypto/x509"
 	"encoding/json"
 	"errors"
 	"flag"
@@ -11,7 +9,9 @@ import (
 	"io"
 	"io/ioutil"
 	"net/http"
+	"net/url"
 	"os"
+	"path"
 	"strconv"
 	"strings"
 	"time"
@@ -19,13 +19,13 @@ import (
 	jira "github.com/andygrunwald/go-jira"
 	"github.com/golang/glog"
 	version "github.com/hashicorp/go-version"
-	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 )
 
 //AvailableImageData type
 type AvailableImageData interface {
 	tag() string
+	created() time.Time
 }
 
 //DockerTag type representing the json schema of docker registry versions page
@@ -37,18 +37,25 @@ type DockerTag struct {
 
 //V2Tag type
 type V2Tag struct {
-	Name string
+	Name    string
+	Created time.Time
 }
 
 //GcrTag type
 type GcrTag struct {
-	Name string
+	Name    string
+	Created time.Time
 }
 
-//SlackMsg type
+//SlackMsg type. With Blocks, Text is only used for notifications. TS and ThreadTS are only used with the Web API,
+// to update a message and reply in a thread respectively
 type SlackMsg struct {
-	Text     string `json:"text"`
-	Username string `json:"username"`
+	Text     string       `json:"text"`
+	Username string       `json:"username"`
+	Channel  string       `json:"channel,omitempty"`
+	Blocks   []SlackBlock `json:"blocks,omitempty"`
+	TS       string       `json:"ts,omitempty"`
+	ThreadTS string       `json:"thread_ts,omitempty"`
 }
 
 //InspectrResult type
@@ -58,146 +65,320 @@ type InspectrResult struct {
 	Quantity  int64
 	Upgrades  []string
 	Version   string
-}
-
+	//LatestVersion is the newest of the Upgrades
+	LatestVersion string
+	//VersionsBehind is the number of releases newer than Version
+	VersionsBehind int
+	//DaysBehind is the number of days between Version and LatestVersion being
+	// created, or -1 if the registry doesn't expose creation times
+	DaysBehind int
+	//UpgradeClass is how significant the upgrade to LatestVersion is: major, minor, patch or unknown
+	UpgradeClass string
+	//Ownership is who owns the workload, from its (or its namespace's) annotations
+	Ownership Ownership
+	//Labels are the pod's labels
+	Labels map[string]string
+}
+
+//filters applied to every scan, set from the config by applyConfig
 var (
-	upgradeNum = prometheus.NewGauge(prometheus.GaugeOpts{
-		Name: "inspectr_upgrades_total",
-		Help: "Number of image upgrades currently available.",
-	})
-	ignoreImages = map[string][]string{
-		"gcr.io/google_containers/nginx-ingress-controller": {"0.61", "0.62"}}
-	ignoreNamespaces = map[string]struct{}{
-		"kube-system": struct{}{},
-	}
-	ignoreTags = map[string]struct{}{
-		"latest": struct{}{},
-	}
-	allowedPodPhases = map[string]struct{}{
-		"Running": struct{}{},
-	}
+	ignoreImages      map[string][]string
+	includeNamespaces []string
+	ignoreNamespaces  []string
+	podSelector       string
+	namespaceSelector string
+	ignoreTags        map[string]struct{}
+	allowedPodPhases  map[string]struct{}
 )
 
-func init() {
-	// Metrics have to be registered to be exposed:
-	prometheus.MustRegister(upgradeNum)
-}
+var (
+	//namespacesUnlistable records the clusters (by api url) whose namespaces can't be listed for their annotations,
+	// e.g. for lack of RBAC, so that's warned about once rather than on every scan. It's only used by the scan loop
+	namespacesUnlistable = make(map[string]bool)
+	//lastNamespaceMetadata is the namespace metadata last listed for each cluster (by api url). It's used while the
+	// cluster's namespaces can't be listed, so that namespaces which opted out of scans stay opted out. It's only used
+	// by the scan loop
+	lastNamespaceMetadata = make(map[string]map[string]NamespaceMetadata)
+)
 
 func main() {
 	flag.Parse()
+	apiTokenKey := "INSPECTR_API_TOKEN"
+	apiToken := os.Getenv(apiTokenKey)
+	if flag.Arg(0) == "trigger" {
+		os.Exit(runTriggerCommand(flag.Args()[1:], apiToken))
+	}
 	glog.Info("hello inspectr")
-	registeredImages := make(map[string][]string)
-	glog.Info("initialized local image registry cache")
-	slackWebhookKey := "INSPECTR_SLACK_WEBHOOK_ID"
-	jiraURLKey := "INSPECTR_JIRA_URL"
-	jiraParamKey := "INSPECTR_JIRA_PARAMS"
-	timezoneKey := "INSPECTR_TIMEZONE"
-	scheduleKey := "INSPECTR_SCHEDULE"
-	webhookID := os.Getenv(slackWebhookKey)
-	jiraURL := os.Getenv(jiraURLKey)
-	jiraParams := os.Getenv(jiraParamKey)
-	timezone := os.Getenv(timezoneKey)
-	schedule := os.Getenv(scheduleKey)
-	glog.Info("picked up env vars")
-	handleHTTP()
+	stateKey := "INSPECTR_STATE"
+	alertStore, err := alertStoreFromString(os.Getenv(stateKey))
+	if err != nil {
+		glog.Fatal(err)
+	}
+	leaderElectionKey := "INSPECTR_LEADER_ELECTION_LEASE"
+	hostname, _ := os.Hostname()
+	leaderElector, err := leaderElectorFromString(os.Getenv(leaderElectionKey), hostname)
+	if err != nil {
+		glog.Fatal(err)
+	}
+	if leaderElector != nil {
+		leaderElector.run()
+		glog.Info("started leader election as " + hostname)
+	}
+	configKey := "INSPECTR_CONFIG"
+	configFile, err := loadConfigFile(os.Getenv(configKey))
+	if err != nil {
+		glog.Fatal(err)
+	}
+	glog.Info("picked up config")
+	handleHTTP(apiToken)
 	glog.Info("about to enter life-of-pod loop")
+	fullReport := false
+	leader := false
+	var alertState *AlertState
+	var followed time.Time
 	for {
-		time.Sleep(time.Duration(invokeInspectrProcess(&registeredImages, webhookID,
-			jiraURL, jiraParams, schedule, location(timezone))) * time.Second)
+		sleep := 5
+		config := configFile.reload()
+		applyConfig(config)
+		if isLeader() {
+			if !leader {
+				leader = true
+				alertState = loadAlertState(alertStore)
+				glog.Info("initialized image registry cache")
+			}
+			sleep = invokeInspectrProcess(alertState, alertStore, config, fullReport)
+		} else {
+			leader = false
+			if time.Since(followed) >= followInterval {
+				followLatestScan(alertStore)
+				followed = time.Now()
+			}
+		}
+		fullReport = false
+		select {
+		case <-time.After(time.Duration(sleep) * time.Second):
+		case fullReport = <-scanTriggers:
+			glog.Infof("scan triggered (full report: %t)", fullReport)
+		}
+	}
+}
+
+//loadAlertState returns the alert cache as last persisted, or an empty one if it can't be loaded. It's loaded whenever
+// this inspectr becomes the leader, as another replica may have been alerting in the meantime
+func loadAlertState(alertStore AlertStore) (alertState *AlertState) {
+	alertState, err := alertStore.Load()
+	if err != nil {
+		glog.Error(err, ", starting with an empty alert cache")
+		alertState = newAlertState()
+	}
+	return
+}
+
+//followLatestScan serves the latest scan the leader has saved to alertStore, if any, so that a follower's results api
+// and dashboard show the same results as the leader's
+func followLatestScan(alertStore AlertStore) {
+	scan, err := alertStore.LoadScan()
+	if err != nil {
+		glog.Error(err, ", still serving the previous scan")
+	} else if scan != nil {
+		setLatestScan(scan)
 	}
 }
 
 //handleHTTP starts metrics and monitoring servers in the background
-func handleHTTP() {
+func handleHTTP(apiToken string) {
 	http.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {
 		w.Write([]byte("OK"))
 	})
 	http.Handle("/metrics", promhttp.Handler())
+	http.HandleFunc("/api/v1/results", handleResults)
+	http.HandleFunc("/api/v1/scan", handleTrigger(apiToken))
+	http.HandleFunc("/", handleDashboard)
 	go func() {
 		http.ListenAndServe(":8080", nil)
 	}()
 }
 
-//location returns a time.Location that represents the specified location string
-// "" returns UTC, "UTC" returns UTC, "Local" returns Local, any valid
-// location string returns the location correspnding to the file in the
-// IANA Time Zone database
-// Default is Local
-func location(locationString string) (loc *time.Location) {
-	var err error
-	loc, err = time.LoadLocation(locationString)
-	if err != nil {
-		loc, _ = time.LoadLocation("Local")
-	}
-	return
-}
-
 //invokeInspectrProcess attempts to run through as much of the 'process' as it can. At appropriate points it may
 // abort if it sees an error. If this happens, the error is logged, and a default/long time is returned as the sleep
 // value.
-// if everything goes okay, the sleep value returned is either pretty small (as inspectr should be quick to detect
-// any 'unregistered' images, or a bit longer if current time is withinAlertWindow
-func invokeInspectrProcess(registeredImages *map[string][]string, webhookID,
-	jiraURL, jiraParamString, schedule string, loc *time.Location) (sleep int) {
+// if everything goes okay, the sleep value returned is pretty small (as inspectr should be quick to detect
+// any 'unregistered' images), or shorter still if a scheduled full report is due before then
+// Each output (slack, jira) outputs the full set of results once per occurrence of its schedule, catching up if an
+// occurrence was missed (e.g. while inspectr wasn't running). forceFullReport makes every output do so, without
+// counting as a scheduled report. Results are split up between receivers by the config's routing tree, see
+// notifyReceivers
+// The alert cache and last full report times in alertState are written to alertStore after outputting, if they've
+// changed since they were last saved. The scan is then saved to alertStore separately, for followers to serve
+func invokeInspectrProcess(alertState *AlertState, alertStore AlertStore, config *Config,
+	forceFullReport bool) (sleep int) {
 	sleep = 300
-	var k8sJSONData *Data
-	var err error
-	k8sJSONData, err = jsonData()
+	scanStart := time.Now()
+	schedules := config.schedules
+	resultsMap, err := clustersResultsMap(config.Clusters)
 	if err == nil {
-		withinAlertWindow := withinAlertWindow(schedule, loc)
-		var upgradeMap map[string][]InspectrResult
-		upgradeMap, err = upgradesMap(imageToResultsMap(k8sJSONData))
-		if err == nil {
-			upgradeNum.Set(float64(len(upgradeMap)))
-			upgradeMap = filterUpgradesMap(upgradeMap, *registeredImages, withinAlertWindow)
-			augmentInternalImageRegistry(upgradeMap, *registeredImages, withinAlertWindow)
-			outputResults(upgradeMap, webhookID, withinAlertWindow)
-			reportResults(upgradeMap, jiraURL, jiraParamString, webhookID)
-			sleep = sleepTime(withinAlertWindow)
+		upgradeMap, registryErrors := upgradesMap(resultsMap)
+		setResultMetrics(upgradeMap)
+		updateUpgradeHistory(resultsMap, upgradeMap, time.Now())
+		scanEnd := time.Now()
+		now := scanEnd.In(config.location)
+		notifyReceivers(alertState, config, receiverNotifiers(config, alertState), upgradeMap, registryErrors,
+			forceFullReport, now)
+		//built after notifying, so it links to any issues the outputs have just created (or closed)
+		scan := newScan(scanStart, scanEnd, upgradeMap, registryErrors)
+		setLatestScan(scan)
+		saveErr := alertStore.Save(alertState)
+		if saveErr != nil {
+			glog.Error(saveErr)
 		}
+		saveErr = alertStore.SaveScan(scan)
+		if saveErr != nil {
+			glog.Error(saveErr, ", followers will keep serving an older scan")
+		}
+		now = time.Now().In(config.location)
+		sleep = sleepTime(now, nextScheduled(schedules, now))
 	}
+	setScanMetrics(scanStart, err)
 	if err != nil {
 		glog.Error(err, ", going to sleep for "+strconv.Itoa(sleep)+" seconds")
 	}
 	return
 }
 
-//jsonData returns a Data struct based on what k8s master returns, and an error
-func jsonData() (jsonData *Data, err error) {
-	bodyReader, err := bodyFromMaster()
-	if err == nil {
-		jsonData, err = decodeData(bodyReader)
-		bodyReader.Close()
+//clustersResultsMap returns the results for the pods in all of the specified clusters. If any cluster can't be
+// listed, it returns an error rather than results for only some of the clusters (which would look like workloads had
+// gone from the rest)
+func clustersResultsMap(clusters []ClusterConfig) (resultsMap map[string][]InspectrResult, err error) {
+	resultsMap = make(map[string][]InspectrResult)
+	for _, cluster := range clusters {
+		var api *K8sAPI
+		api, err = clusterK8sAPI(cluster)
+		if err == nil {
+			var k8sJSONData *Data
+			var namespaces map[string]NamespaceMetadata
+			k8sJSONData, namespaces, err = clusterPods(api, cluster.Namespaces)
+			if err == nil {
+				project, name := cluster.Project, cluster.Name
+				if project == "" {
+					project = projectName()
+				}
+				if name == "" {
+					name = clusterName()
+				}
+				for k, v := range imageToResultsMap(k8sJSONData, namespaces, project, name) {
+					resultsMap[k] = v
+				}
+			}
+		}
+		if err != nil {
+			err = errors.New("cluster \"" + cluster.Name + "\": " + err.Error())
+			resultsMap = nil
+			break
+		}
 	}
-	return jsonData, err
+	return
 }
 
-//sleepTime returns an int of the number of seconds to go to sleep for. Sleep is needed so the process isn't
-// constantly running, and doesn't run more than once in the alert window
-func sleepTime(withinAlertWindow bool) (sleepTime int) {
-	if withinAlertWindow {
-		sleepTime = 360
-	} else {
-		sleepTime = 60
+//clusterPods returns the pods matching the podSelector in the specified cluster: listed cluster-wide, or from each of
+// the specified namespaces if there are any (so inspectr only needs namespace-scoped RBAC). If there's a
+// namespaceSelector, pods in namespaces that don't match it are left out
+//
+// It also returns the namespaces' metadata, for their annotations. That needs namespaces listing cluster-wide, so it's
+// only fetched when pods are listed cluster-wide. If it can't be, that's logged rather than failing the scan, and the
+// metadata last listed is returned instead, if any (without any, namespace annotations are ignored)
+func clusterPods(api *K8sAPI, namespaces []string) (pods *Data, namespaceMetadata map[string]NamespaceMetadata,
+	err error) {
+
+	query := ""
+	if podSelector != "" {
+		query = "?labelSelector=" + url.QueryEscape(podSelector)
+	}
+	paths := []string{"/api/v1/pods" + query}
+	if len(namespaces) > 0 {
+		paths = nil
+		for _, namespace := range namespaces {
+			paths = append(paths, "/api/v1/namespaces/"+namespace+"/pods"+query)
+		}
+	}
+	pods = new(Data)
+	for _, path := range paths {
+		var pathPods *Data
+		pathPods, err = jsonData(api, path)
+		if err != nil {
+			pods = nil
+			return
+		}
+		pods.Items = append(pods.Items, pathPods.Items...)
+	}
+	if namespaceSelector != "" {
+		namespaceMetadata, err = clusterNamespaces(api, namespaceSelector)
+		if err == nil {
+			items := pods.Items[:0]
+			for _, item := range pods.Items {
+				if _, ok := namespaceMetadata[item.Metadata.Namespace]; ok {
+					items = append(items, item)
+				}
+			}
+			pods.Items = items
+		} else {
+			pods = nil
+		}
+	} else if len(namespaces) == 0 {
+		var namespacesErr error
+		namespaceMetadata, namespacesErr = clusterNamespaces(api, "")
+		if namespacesErr == nil {
+			lastNamespaceMetadata[api.BaseURL] = namespaceMetadata
+		} else {
+			namespaceMetadata = lastNamespaceMetadata[api.BaseURL]
+			if !namespacesUnlistable[api.BaseURL] {
+				if namespaceMetadata != nil {
+					glog.Warning(namespacesErr, ", using the namespace annotations last listed until namespaces can "+
+						"be listed again")
+				} else {
+					glog.Warning(namespacesErr, ", ignoring namespace annotations (including inspectr.io/exclude "+
+						"opt-outs) until namespaces can be listed")
+				}
+			}
+		}
+		namespacesUnlistable[api.BaseURL] = namespacesErr != nil
 	}
 	return
 }
 
-//withinAlertWindow returns a bool indicating whether current time is within the scheduled daily/weekly alert window
-func withinAlertWindow(schedule string, loc *time.Location) (withinAlertWindow bool) {
-	scheduleSplit := strings.Split(schedule, "|")
-	dayOfWeek := strings.ToUpper(scheduleSplit[0])
-	isWeekly := isValidDayOfWeek(dayOfWeek)
-	now := time.Now().In(loc)
-	preferredAlertTime := timeFromSchedule(scheduleSplit, isWeekly, now, loc)
-	if !isWeekly || strings.ToUpper(now.Weekday().String()) == dayOfWeek {
-		windowSize := 300
-		windowEnd := preferredAlertTime.Add(time.Duration(windowSize) * time.Second)
-		withinAlertWindow = now.After(preferredAlertTime) && now.Before(windowEnd)
+//clusterNamespaces returns the metadata of the namespaces matching the specified label selector (or all namespaces if
+// it's empty), keyed by name
+func clusterNamespaces(api *K8sAPI, selector string) (namespaces map[string]NamespaceMetadata, err error) {
+	path := "/api/v1/namespaces"
+	if selector != "" {
+		path += "?labelSelector=" + url.QueryEscape(selector)
+	}
+	var bodyReader io.ReadCloser
+	bodyReader, err = bodyFromMaster(api, path)
+	if err == nil {
+		defer bodyReader.Close()
+		namespaceList := new(NamespaceList)
+		err = json.NewDecoder(bodyReader).Decode(namespaceList)
+		if err == nil {
+			namespaces = make(map[string]NamespaceMetadata)
+			for _, item := range namespaceList.Items {
+				namespaces[item.Metadata.Name] = item.Metadata
+			}
+		}
 	}
 	return
 }
 
+//jsonData returns a Data struct based on what the specified k8s master returns for the specified path, and an error
+func jsonData(api *K8sAPI, path string) (jsonData *Data, err error) {
+	bodyReader, err := bodyFromMaster(api, path)
+	if err == nil {
+		jsonData, err = decodeData(bodyReader)
+		bodyReader.Close()
+	}
+	return jsonData, err
+}
+
+//isValidDayOfWeek returns a bool indicating whether the specified upper case string is a day of the week
 func isValidDayOfWeek(day string) (isDayOfWeek bool) {
 	isDayOfWeek = day == "MONDAY" || day == "TUESDAY" ||
 		day == "WEDNESDAY" || day == "THURSDAY" || day == "FRIDAY" ||
@@ -205,35 +386,6 @@ func isValidDayOfWeek(day string) (isDayOfWeek bool) {
 	return
 }
 
-func timeFromSchedule(scheduleSplit []string, isWeekly bool, now time.Time,
-	loc *time.Location) (preferredAlertTime time.Time) {
-	var scheduleTimeString string
-	hourOfDay := 10
-	minOfHour := 0
-	if isWeekly {
-		if len(scheduleSplit) == 2 && len(scheduleSplit[1]) == 4 {
-			scheduleTimeString = scheduleSplit[1]
-		}
-	} else {
-		if len(scheduleSplit[0]) == 4 {
-			scheduleTimeString = scheduleSplit[0]
-		}
-	}
-	if scheduleTimeString != "" {
-		hourInt, err := strconv.Atoi(scheduleTimeString[0:2])
-		if err == nil {
-			minInt, err := strconv.Atoi(scheduleTimeString[2:4])
-			if err == nil {
-				hourOfDay = hourInt
-				minOfHour = minInt
-			}
-		}
-	}
-	preferredAlertTime = time.Date(now.Year(), now.Month(), now.Day(), hourOfDay,
-		minOfHour, 0, 0, loc)
-	return
-}
-
 //filterUpgradesMap returns a map which is based on the one specified, but has any already registered upgrade
 // opportunities removed. If we're withinAlertWindow, no filtering happens
 func filterUpgradesMap(upgradesMap map[string][]InspectrResult, registeredImages map[string][]string,
@@ -301,14 +453,12 @@ func augmentInternalImageRegistry(upgradesMap map[string][]InspectrResult, regis
 
 //registeredImages returns a string<-->string map that reflects the string<-->[]InspectrResult map
 func registeredImages(upgradesMap map[string][]InspectrResult) (registeredImages map[string][]string) {
+	registeredImages = make(map[string][]string, 0)
 	for k, v := range upgradesMap {
 		registeredImageSlice := make([]string, 0)
 		for _, upgradeResult := range v {
 			registeredImageSlice = append(registeredImageSlice, registeredImageString(upgradeResult))
 		}
-		if registeredImages == nil {
-			registeredImages = make(map[string][]string, 0)
-		}
 		registeredImages[k] = registeredImageSlice
 	}
 	return
@@ -329,33 +479,44 @@ func registeredImageString(result InspectrResult) (resultString string) {
 	return
 }
 
-//upgradesMap returns a string <--> []InspectrResult only for those images with upgrades available
-func upgradesMap(imageToResultsMap map[string][]InspectrResult) (upgradesMap map[string][]InspectrResult, err error) {
+//upgradesMap returns a string <--> []InspectrResult only for those images with upgrades available, and any errors
+// encountered talking to registries, keyed by registry. An image whose registry errors is left out of the map, but
+// doesn't stop the other images being looked up
+func upgradesMap(imageToResultsMap map[string][]InspectrResult) (upgradesMap map[string][]InspectrResult,
+	registryErrors map[string][]string) {
+
 	upgradesMap = make(map[string][]InspectrResult)
+	registryErrors = make(map[string][]string)
 	for k, v := range imageToResultsMap {
 		imageString := imageFromInspectrMapKey(k)
+		registryConfig := registryConfig(imageString)
+		registry := registryConfig.Host
 		var availImages []AvailableImageData
-		switch {
-		case strings.Contains(imageString, "gcr.io"):
-			availImages, err = gcrTagSlice(imageString)
-		case strings.Contains(imageString, "quay.io"):
-			availImages, err = v2TagSlice("quay.io", imageString)
-		case strings.Contains(imageString, "zalan.do"):
-			availImages, err = v2TagSlice("zalan.do", imageString)
+		var err error
+		switch registryConfig.API {
+		case "gcr":
+			availImages, err = gcrTagSlice(registry, imageString)
+		case "v2":
+			availImages, err = v2TagSlice(registry, imageString)
 		default:
 			availImages, err = dockerTagSlice(imageString)
 		}
-		if err == nil {
+		if err != nil {
+			glog.Warning(err)
+			registryErrors[registry] = append(registryErrors[registry], err.Error())
+		} else {
 			upgradesResults := make([]InspectrResult, 0)
 			tagsToIgnore, ignoreImageOk := ignoreImages[imageString]
+			policy := versionPolicy(imageString)
 			for _, result := range v {
 				for _, upgradeVersion := range upgradeCandidateSlice(result.Version, []AvailableImageData(availImages)) {
 					version := upgradeVersion.tag()
-					if !ignoreImageOk || !contains(tagsToIgnore, version) {
+					if (!ignoreImageOk || !contains(tagsToIgnore, version)) && policy.allows(result.Version, version) {
 						result.Upgrades = append(result.Upgrades, version)
 					}
 				}
 				if len(result.Upgrades) > 0 {
+					result = lagResult(result, imageString, availImages)
 					upgradesResults = append(upgradesResults, result)
 				}
 			}
@@ -367,21 +528,129 @@ func upgradesMap(imageToResultsMap map[string][]InspectrResult) (upgradesMap map
 	return
 }
 
+//registryFromImage returns the host of the registry the specified image is hosted on, defaulting to dockerhub
+func registryFromImage(imageString string) (registry string) {
+	registry = registryConfig(imageString).Host
+	return
+}
+
 //Dockertag implementation of AvailableImageData
 func (dockerTag DockerTag) tag() string {
 	return dockerTag.Name
 }
 
+//Dockertag implementation of AvailableImageData. The v1 tags api doesn't expose creation times, so this is always
+// the zero time
+func (dockerTag DockerTag) created() time.Time {
+	return time.Time{}
+}
+
 //V2Tag implementation of AvailableImageData
 func (v2Tag V2Tag) tag() string {
 	return v2Tag.Name
 }
 
+//V2Tag implementation of AvailableImageData
+func (v2Tag V2Tag) created() time.Time {
+	return v2Tag.Created
+}
+
 //GcrTag implementaton of AvailableImageData
 func (gcrTag GcrTag) tag() string {
 	return gcrTag.Name
 }
 
+//GcrTag implementaton of AvailableImageData
+func (gcrTag GcrTag) created() time.Time {
+	return gcrTag.Created
+}
+
+//lagResult returns the specified InspectrResult augmented with how many releases behind, and how many days
+// older than, the newest upgrade its version is
+func lagResult(result InspectrResult, imageString string, availImages []AvailableImageData) InspectrResult {
+	result.LatestVersion = latestVersion(result.Upgrades)
+	result.VersionsBehind = len(result.Upgrades)
+	result.DaysBehind = -1
+	result.UpgradeClass = upgradeClass(result.Version, result.LatestVersion)
+	currentCreated := tagCreated(imageString, result.Version, availImages)
+	latestCreated := tagCreated(imageString, result.LatestVersion, availImages)
+	if !currentCreated.IsZero() && !latestCreated.IsZero() {
+		result.DaysBehind = daysBetween(currentCreated, latestCreated)
+	}
+	return result
+}
+
+//latestVersion returns the greatest of the specified version strings, or "" if none of them can be parsed
+func latestVersion(versionStrings []string) (latest string) {
+	var latestVersion *version.Version
+	for _, versionString := range versionStrings {
+		v, err := version.NewVersion(versionString)
+		if err == nil && (latestVersion == nil || v.GreaterThan(latestVersion)) {
+			latestVersion = v
+			latest = versionString
+		}
+	}
+	return
+}
+
+//upgradeClass returns "major", "minor" or "patch" depending on the most significant version segment that differs
+// between the current and latest versions, or "unknown" if either can't be parsed
+func upgradeClass(currentVersion, latestVersion string) (class string) {
+	class = "unknown"
+	current, err := version.NewVersion(currentVersion)
+	if err == nil {
+		var latest *version.Version
+		latest, err = version.NewVersion(latestVersion)
+		if err == nil {
+			currentSegments := current.Segments()
+			latestSegments := latest.Segments()
+			switch {
+			case currentSegments[0] != latestSegments[0]:
+				class = "major"
+			case currentSegments[1] != latestSegments[1]:
+				class = "minor"
+			default:
+				class = "patch"
+			}
+		}
+	}
+	return
+}
+
+//daysBetween returns the number of whole days from one time to another, never less than 0
+func daysBetween(from, to time.Time) (days int) {
+	days = int(to.Sub(from).Hours() / 24)
+	if days < 0 {
+		days = 0
+	}
+	return
+}
+
+//tagCreated returns the creation time of the specified tag, or the zero time if it isn't known.
+// v2 registries don't list creation times alongside tags, so these are looked up (and remembered in availImages)
+// on demand
+func tagCreated(imageString, tag string, availImages []AvailableImageData) (created time.Time) {
+	for i, availImage := range availImages {
+		if availImage.tag() == tag {
+			created = availImage.created()
+			if created.IsZero() {
+				registry := registryConfig(imageString)
+				if registry.API == "v2" {
+					var err error
+					created, err = v2TagCreated(registry.Host, imageString, tag)
+					if err == nil {
+						availImages[i] = V2Tag{tag, created}
+					} else {
+						glog.Warning(err)
+					}
+				}
+			}
+			break
+		}
+	}
+	return
+}
+
 //upgradeCandidateSlice returns a slice of AvailableImageData types that are deemed to be upgrades to the version
 //specified
 func upgradeCandidateSlice(versionString string, availImagesData []AvailableImageData) (upgradeCandidates []AvailableImageData) {
@@ -416,16 +685,17 @@ func contains(s []string, e string) (contains bool) {
 }
 
 //imageToResultsMap returns a map of image <--> InspectrResult type, constructed from what's deemed to be valid pods
-// in rs json from k8s master
-func imageToResultsMap(jsonData *Data) (imageToResultsMap map[string][]InspectrResult) {
+// in rs json from k8s master. Pods that are excluded by their (or their namespace's) annotations are left out
+func imageToResultsMap(jsonData *Data, namespaces map[string]NamespaceMetadata, projectName, clusterName string) (
+	imageToResultsMap map[string][]InspectrResult) {
+
 	imageToResultsMap = make(map[string][]InspectrResult)
-	projectName := projectName()
-	clusterName := clusterName()
 	for _, item := range jsonData.Items {
 		metadata := item.Metadata
 		namespace := metadata.Namespace
-		_, ok := ignoreNamespaces[namespace]
-		if !ok {
+		namespaceAnnotations := namespaces[namespace].Annotations
+		if namespaceIncluded(namespace) && !excluded(namespaceAnnotations, metadata.Annotations) {
+			ownership := ownershipFromAnnotations(namespaceAnnotations, metadata.Annotations)
 			phase := item.Status.Phase
 			_, ok := allowedPodPhases[phase]
 			if ok {
@@ -435,7 +705,7 @@ func imageToResultsMap(jsonData *Data) (imageToResultsMap map[string][]InspectrR
 					if len(splitImage) > 1 {
 						image := imageFromURI(containerImage)
 						inspectrResult := InspectrResult{image, namespace,
-							1, nil, versionFromURI(splitImage)}
+							1, nil, versionFromURI(splitImage), "", 0, -1, "", ownership, metadata.Labels}
 						clusterImageString := projectName + ":" + clusterName + ":" +
 							image + ":" + podName(metadata.Name) + ":" + container.Name
 						inspectrResults, ok := imageToResultsMap[clusterImageString]
@@ -453,6 +723,25 @@ func imageToResultsMap(jsonData *Data) (imageToResultsMap map[string][]InspectrR
 	return
 }
 
+//namespaceIncluded returns a bool indicating whether pods in the specified namespace should be scanned: it has to
+// match one of the includeNamespaces globs (if there are any), and none of the ignoreNamespaces globs
+func namespaceIncluded(namespace string) (included bool) {
+	included = len(includeNamespaces) == 0
+	for _, pattern := range includeNamespaces {
+		if matched, _ := path.Match(pattern, namespace); matched {
+			included = true
+			break
+		}
+	}
+	for _, pattern := range ignoreNamespaces {
+		if matched, _ := path.Match(pattern, namespace); matched {
+			included = false
+			break
+		}
+	}
+	return
+}
+
 //podName returns a generic pod name from an actual full pod name
 //
 // This is assuming that all pods are suffixed by 2 strings separated by "-"
@@ -526,31 +815,17 @@ func addInspectrResult(inspectrResults []InspectrResult, inspectrResult Inspectr
 	return inspectrResults
 }
 
-//bodyFromMaster returns a ReadCloser from the k8s master's rs, and an error
-func bodyFromMaster() (r io.ReadCloser, err error) {
-	var caCert []byte
-	caCert, err = ioutil.ReadFile("/var/run/secrets/kubernetes.io/serviceaccount/ca.crt")
+//bodyFromMaster returns a ReadCloser from the k8s master's response for the specified path, and an error if the
+// request fails or the response isn't a 200 (e.g. inspectr isn't allowed to list the pods)
+func bodyFromMaster(api *K8sAPI, path string) (r io.ReadCloser, err error) {
+	var resp *http.Response
+	resp, err = api.do("GET", path, nil)
 	if err == nil {
-		caCertPool := x509.NewCertPool()
-		caCertPool.AppendCertsFromPEM(caCert)
-		client := &http.Client{
-			Transport: &http.Transport{
-				TLSClientConfig: &tls.Config{
-					RootCAs: caCertPool,
-				},
-			},
-			Timeout: 30 * time.Second,
-		}
-		req, _ := http.NewRequest("GET", "https://kubernetes.default/api/v1/pods", nil)
-		var token []byte
-		token, err = ioutil.ReadFile("/var/run/secrets/kubernetes.io/serviceaccount/token")
-		if err == nil {
-			req.Header.Set("Authorization", "Bearer "+string(token))
-			var resp *http.Response
-			resp, err = client.Do(req)
-			if err == nil {
-				r = resp.Body
-			}
+		if resp.StatusCode == http.StatusOK {
+			r = resp.Body
+		} else {
+			err = k8sStatusError(resp, "GET", path)
+			resp.Body.Close()
 		}
 	}
 	return
@@ -586,25 +861,49 @@ func decodeV2Tag(r io.Reader) (v2Tags []V2Tag, err error) {
 func decodeGcrTag(r io.Reader) (gcrTags []GcrTag, err error) {
 	x := new(Gcr)
 	err = json.NewDecoder(r).Decode(x)
+	createdTimes := make(map[string]time.Time)
+	for _, image := range x.Manifest {
+		for _, tag := range image.Tag {
+			if image.TimeCreatedMs > 0 {
+				createdTimes[tag] = time.Unix(0, image.TimeCreatedMs*int64(time.Millisecond))
+			}
+		}
+	}
 	for _, tag := range []string(x.Tags) {
 		var gcrTag = new(GcrTag)
 		gcrTag.Name = tag
+		gcrTag.Created = createdTimes[tag]
 		gcrTags = append(gcrTags, *gcrTag)
 	}
 	return
 }
 
-//dockerTagSlice returns an AvailableImageData slice representing all available tags for the specified repo
+//decodeV2Manifest returns a V2Manifest, decoded from the specified Reader, and an error
+func decodeV2Manifest(r io.Reader) (x *V2Manifest, err error) {
+	x = new(V2Manifest)
+	err = json.NewDecoder(r).Decode(x)
+	return
+}
+
+//decodeV2ConfigBlob returns a V2ConfigBlob, decoded from the specified Reader, and an error
+func decodeV2ConfigBlob(r io.Reader) (x *V2ConfigBlob, err error) {
+	x = new(V2ConfigBlob)
+	err = json.NewDecoder(r).Decode(x)
+	return
+}
+
+//dockerTagSlice returns an AvailableImageData slice representing all available tags for the specified repo. It's an
+// error if the registry can't be reached or doesn't respond with a 200, so that its images count as errored
 func dockerTagSlice(repo string) (imagesData []AvailableImageData, err error) {
 	imageURI := "https://registry.hub.docker.com/v1/repositories/" + repo + "/tags"
 	timeout := time.Duration(30 * time.Second)
 	client := http.Client{
 		Timeout: timeout,
 	}
-	resp, err := client.Get(imageURI)
+	resp, err := registryGet(client, imageURI)
 	if err == nil {
+		defer resp.Body.Close()
 		if resp.StatusCode == 200 {
-			defer resp.Body.Close()
 			var dockerTags []DockerTag
 			dockerTags, err = decodeDockerTag(resp.Body)
 			if err == nil {
@@ -613,13 +912,14 @@ func dockerTagSlice(repo string) (imagesData []AvailableImageData, err error) {
 				}
 			}
 		} else {
-			glog.Warning("bad status code (" + strconv.Itoa(resp.StatusCode) + ") trying to access " + imageURI)
+			err = errors.New("bad status code (" + strconv.Itoa(resp.StatusCode) + ") trying to access " + imageURI)
 		}
 	}
 	return
 }
 
-//v2TagSlice returns an AvailableImageData slice representing all available tags for the specified repo
+//v2TagSlice returns an AvailableImageData slice representing all available tags for the specified repo. It's an
+// error if the registry can't be reached or doesn't respond with a 200, so that its images count as errored
 func v2TagSlice(urlPrefix, repo string) (imagesData []AvailableImageData, err error) {
 	repo = strings.Replace(repo, urlPrefix+"/", "", 1)
 	imageURI := "https://" + urlPrefix + "/v2/" + repo + "/tags/list"
@@ -627,10 +927,10 @@ func v2TagSlice(urlPrefix, repo string) (imagesData []AvailableImageData, err er
 	client := http.Client{
 		Timeout: timeout,
 	}
-	resp, err := client.Get(imageURI)
+	resp, err := registryGet(client, imageURI)
 	if err == nil {
+		defer resp.Body.Close()
 		if resp.StatusCode == 200 {
-			defer resp.Body.Close()
 			var v2Tags []V2Tag
 			v2Tags, err = decodeV2Tag(resp.Body)
 			if err == nil {
@@ -639,24 +939,80 @@ func v2TagSlice(urlPrefix, repo string) (imagesData []AvailableImageData, err er
 				}
 			}
 		} else {
-			glog.Warning("bad status code (" + strconv.Itoa(resp.StatusCode) + ") trying to access " + imageURI)
+			err = errors.New("bad status code (" + strconv.Itoa(resp.StatusCode) + ") trying to access " + imageURI)
+		}
+	}
+	return
+}
+
+//v2TagCreated returns the creation time of the specified tag, read from the config blob its manifest points to.
+// If the tag is a manifest list, the first manifest in the list is used
+func v2TagCreated(urlPrefix, repo, tag string) (created time.Time, err error) {
+	repo = strings.Replace(repo, urlPrefix+"/", "", 1)
+	baseURI := "https://" + urlPrefix + "/v2/" + repo
+	client := http.Client{
+		Timeout: time.Duration(30 * time.Second),
+	}
+	var manifest *V2Manifest
+	reference := tag
+	for i := 0; i < 2 && err == nil; i++ {
+		req, _ := http.NewRequest("GET", baseURI+"/manifests/"+reference, nil)
+		req.Header.Set("Accept", "application/vnd.docker.distribution.manifest.v2+json, "+
+			"application/vnd.docker.distribution.manifest.list.v2+json, "+
+			"application/vnd.oci.image.manifest.v1+json, application/vnd.oci.image.index.v1+json")
+		var resp *http.Response
+		resp, err = registryDo(client, req)
+		if err == nil {
+			if resp.StatusCode == 200 {
+				manifest, err = decodeV2Manifest(resp.Body)
+			} else {
+				err = errors.New("bad status code (" + strconv.Itoa(resp.StatusCode) + ") trying to access " +
+					baseURI + "/manifests/" + reference)
+			}
+			resp.Body.Close()
+		}
+		if err != nil || manifest.Config.Digest != "" || len(manifest.Manifests) == 0 {
+			break
+		}
+		reference = manifest.Manifests[0].Digest
+	}
+	if err == nil {
+		if manifest.Config.Digest == "" {
+			err = errors.New("no config blob found for " + baseURI + ":" + tag)
+		} else {
+			var resp *http.Response
+			resp, err = registryGet(client, baseURI+"/blobs/"+manifest.Config.Digest)
+			if err == nil {
+				defer resp.Body.Close()
+				if resp.StatusCode == 200 {
+					var configBlob *V2ConfigBlob
+					configBlob, err = decodeV2ConfigBlob(resp.Body)
+					if err == nil {
+						created = configBlob.Created
+					}
+				} else {
+					err = errors.New("bad status code (" + strconv.Itoa(resp.StatusCode) + ") trying to access " +
+						baseURI + "/blobs/" + manifest.Config.Digest)
+				}
+			}
 		}
 	}
 	return
 }
 
-//gcrTagSlice returns an AvailableImageData slice representing all available tags for the specified repo
-func gcrTagSlice(repo string) (imagesData []AvailableImageData, err error) {
-	repo = strings.Replace(repo, "gcr.io/", "", 1)
-	imageURI := "https://gcr.io/v2/" + repo + "/tags/list"
+//gcrTagSlice returns an AvailableImageData slice representing all available tags for the specified repo. It's an
+// error if the registry can't be reached or doesn't respond with a 200, so that its images count as errored
+func gcrTagSlice(urlPrefix, repo string) (imagesData []AvailableImageData, err error) {
+	repo = strings.Replace(repo, urlPrefix+"/", "", 1)
+	imageURI := "https://" + urlPrefix + "/v2/" + repo + "/tags/list"
 	timeout := time.Duration(30 * time.Second)
 	client := http.Client{
 		Timeout: timeout,
 	}
-	resp, err := client.Get(imageURI)
+	resp, err := registryGet(client, imageURI)
 	if err == nil {
+		defer resp.Body.Close()
 		if resp.StatusCode == 200 {
-			defer resp.Body.Close()
 			var gcrTags []GcrTag
 			gcrTags, err = decodeGcrTag(resp.Body)
 			if err == nil {
@@ -665,51 +1021,56 @@ func gcrTagSlice(repo string) (imagesData []AvailableImageData, err error) {
 				}
 			}
 		} else {
-			glog.Warning("bad status code (" + strconv.Itoa(resp.StatusCode) + ") trying to access " + imageURI)
+			err = errors.New("bad status code (" + strconv.Itoa(resp.StatusCode) + ") trying to access " + imageURI)
 		}
 	}
 	return
 }
 
-//outputResults outputs the specified results to various places, provided there's results and/or current timestamp is
-//within the scheduled alert window
-// It doesn't return anything.
-func outputResults(upgradeMap map[string][]InspectrResult, webhookID string, withinAlertWindow bool) {
-	if len(upgradeMap) > 0 || withinAlertWindow {
-		glog.Info("latest results: " + fmt.Sprintf("%#v", upgradeMap))
-		postResultToSlack(upgradeMap, webhookID)
-	}
+//outputResults posts the specified results to the slack webhook's channel. Slack's incoming webhooks can only post
+// to their own channel, so the inspectr.io/slack-channel annotation isn't used here, only by the bot token output
+// (see slackAPINotifier.postResults)
+func outputResults(upgradeMap map[string][]InspectrResult, slackConfig SlackConfig, fullReport bool) (err error) {
+	glog.Info("latest results: " + fmt.Sprintf("%#v", upgradeMap))
+	err = postResultToSlack(upgradeMap, slackConfig, fullReport)
+	return
 }
 
-//postResultToSlack posts a string representation of the inspectrResultMap to slack
-//It doesn't return anything.
-func postResultToSlack(upgradeMap map[string][]InspectrResult, webhookID string) {
-	var buffer bytes.Buffer
-	newLineString := "\n"
-	codeSep := "```"
+//slackChannelMaps returns the specified results split up by the slack channel their owners want them in (from the
+// inspectr.io/slack-channel annotation), with "" being the configured channel
+func slackChannelMaps(upgradeMap map[string][]InspectrResult) (channelMaps map[string]map[string][]InspectrResult) {
+	channelMaps = make(map[string]map[string][]InspectrResult)
 	for k, v := range upgradeMap {
-		buffer.WriteString(codeSep)
-		buffer.WriteString("project: ")
-		buffer.WriteString(projectFromInspectrMapKey(k))
-		buffer.WriteString(newLineString)
-		buffer.WriteString("cluster: ")
-		buffer.WriteString(clusterFromInspectrMapKey(k))
-		buffer.WriteString(newLineString)
-		buffer.WriteString("image: ")
-		buffer.WriteString(imageFromInspectrMapKey(k))
-		buffer.WriteString(newLineString)
-		buffer.WriteString("namespaces: ")
-		buffer.WriteString(namespaceStringFromInspectrResults(v))
-		buffer.WriteString(newLineString)
-		buffer.WriteString("current-versions: ")
-		buffer.WriteString(currentVersionStringFromInspectrResults(v))
-		buffer.WriteString(newLineString)
-		buffer.WriteString("new-versions: ")
-		buffer.WriteString(newVersionStringFromInspectrResults(v))
-		buffer.WriteString(codeSep)
-		buffer.WriteString(newLineString)
+		for _, result := range v {
+			channel := result.Ownership.SlackChannel
+			if channelMaps[channel] == nil {
+				channelMaps[channel] = make(map[string][]InspectrResult)
+			}
+			channelMaps[channel][k] = append(channelMaps[channel][k], result)
+		}
+	}
+	return
+}
+
+//postResultToSlack posts the inspectrResultMap to slack as Block Kit messages (see slackMessages). It stops at the
+// first message that fails to post
+func postResultToSlack(upgradeMap map[string][]InspectrResult, slackConfig SlackConfig, fullReport bool) (err error) {
+	for _, slackMsg := range slackMessages(upgradeMap, fullReport) {
+		err = postSlackMsg(slackMsg, slackConfig)
+		if err != nil {
+			break
+		}
+	}
+	return
+}
+
+//ownerStringFromInspectrResults returns a string representing the teams owning the workloads in the InspectrResult
+// slice, or an empty string if none have an owner
+func ownerStringFromInspectrResults(inspectrResults []InspectrResult) (owners string) {
+	if teams := ownersFromInspectrResults(inspectrResults); len(teams) > 0 {
+		owners = cappedSlackString(teams)
 	}
-	postStringToSlack(buffer.String(), webhookID)
+	return
 }
 
 //newVersionStringFromInspectrResults returns a string representing the new
@@ -730,6 +1091,37 @@ func newVersionsFromInspectrResults(inspectrResults []InspectrResult) (versions
 	return
 }
 
+//versionsBehindStringFromInspectrResults returns a string representing how many
+//releases behind each InspectrResult in the slice is
+func versionsBehindStringFromInspectrResults(inspectrResults []InspectrResult) (versionsBehind string) {
+	var versionsBehindSlice []string
+	for _, inspectrResult := range inspectrResults {
+		versionsBehindSlice = append(versionsBehindSlice, strconv.Itoa(inspectrResult.VersionsBehind))
+	}
+	versionsBehind = cappedSlackString(versionsBehindSlice)
+	return
+}
+
+//daysBehindStringFromInspectrResults returns a string representing how many
+//days behind each InspectrResult in the slice is, "?" where it isn't known
+func daysBehindStringFromInspectrResults(inspectrResults []InspectrResult) (daysBehind string) {
+	var daysBehindSlice []string
+	for _, inspectrResult := range inspectrResults {
+		daysBehindSlice = append(daysBehindSlice, daysBehindString(inspectrResult))
+	}
+	daysBehind = cappedSlackString(daysBehindSlice)
+	return
+}
+
+//daysBehindString returns the InspectrResult's DaysBehind as a string, or "?" if it isn't known
+func daysBehindString(inspectrResult InspectrResult) (daysBehind string) {
+	daysBehind = "?"
+	if inspectrResult.DaysBehind >= 0 {
+		daysBehind = strconv.Itoa(inspectrResult.DaysBehind)
+	}
+	return
+}
+
 //currentVersionStringFromInspectrResults returns a string representing the
 //current versions defined in the InspectrResult slice
 func currentVersionStringFromInspectrResults(inspectrResults []InspectrResult) (versions string) {
@@ -787,23 +1179,32 @@ func cappedSlackString(candidateStrings []string) (outputString string) {
 	return
 }
 
-//postStringToSlack posts the specified string to the specified slack webhook.
-// //It doesn't return anything.
-func postStringToSlack(payload, webhookID string) {
-	if len(webhookID) > 0 {
-		slackMsg := SlackMsg{payload, "inspectr"}
-		bytesBuff := new(bytes.Buffer)
-		json.NewEncoder(bytesBuff).Encode(slackMsg)
-		_, err := http.Post("https://hooks.slack.com/services/"+webhookID,
-			"application/json; charset=utf-8", bytesBuff)
-		if err != nil {
-			glog.Error(err)
-		}
+//postStringToSlack posts the specified string to the specified slack webhook
+func postStringToSlack(payload string, slackConfig SlackConfig) error {
+	return postSlackMsg(SlackMsg{Text: payload, Username: "inspectr"}, slackConfig)
+}
+
+//postSlackMsg posts the specified message to the specified slack webhook, returning an error if it doesn't respond
+// with a 2xx status. Errors don't include the webhook's url, as anyone with it can post to the channel
+func postSlackMsg(slackMsg SlackMsg, slackConfig SlackConfig) (err error) {
+	if len(slackConfig.WebhookID) > 0 {
+		err = postJSON(slackConfig.HTTP.httpClient(), slackWebhookURL(slackConfig.WebhookID), "slack webhook",
+			slackMsg)
 	} else {
-		glog.Info("not outputting to slack as the webhookID I've got is empty. Have you set the " +
-			"INSPECTR_SLACK_WEBHOOK_ID env var?")
+		glog.Info("not outputting to slack as the webhookID I've got is empty. Have you set slack.webhookID (or " +
+			"slack.token and slack.channel) in outputs, or in the receiver's config?")
 	}
+	return
+}
 
+//slackWebhookURL returns the url of the specified slack webhook, which can be its id (the part after
+// https://hooks.slack.com/services/) or its full url, e.g. for a slack compatible endpoint such as mattermost's
+func slackWebhookURL(webhookID string) (webhookURL string) {
+	webhookURL = webhookID
+	if !strings.HasPrefix(webhookID, "https://") && !strings.HasPrefix(webhookID, "http://") {
+		webhookURL = "https://hooks.slack.com/services/" + webhookID
+	}
+	return
 }
 
 //imageFromURI returns the image 'name' from a URI. E.g. 'eversc/inspectr' from the URI: 'eversc/inspectr:v0.0.1-alpha'
@@ -866,57 +1267,223 @@ func containerFromInspectrMapKey(mapKey string) (container string) {
 	return
 }
 
-//reportResults updates or creates a JIRA issue based on the upgradeMap provided
-//
-//jiraParamString should be of the form:
-//     user|pass|project|issueType|otherFieldKey:otherFieldValue,otherFieldKey:otherFieldValue
-//
-//  user, pass, project and issueType are mandatory
-//  1:n otherField k:v are optional  (but could be mandatory in your JIRA project)
-//
-//  otherField keys should be as they appear in the JIRA UI
-func reportResults(upgradeMap map[string][]InspectrResult, jiraURL, jiraParamString, webhookID string) {
-	jiraParamStrings := strings.Split(jiraParamString, "|")
+//reportResults updates or creates a JIRA issue for each image in the upgradeMap provided, calling events with what
+// it's done, and recording the issues in trackedIssues if it isn't nil. If an image can't be reported, the others
+// still are, and the last error is returned
+func reportResults(upgradeMap map[string][]InspectrResult, jiraConfig JiraConfig, trackedIssues map[string]*JiraIssue,
+	events func(NotifierEvent)) (err error) {
+
+	var jiraClient *jira.Client
+	jiraClient, err = newJiraClient(jiraConfig)
+	if err == nil {
+		for k, v := range upgradeMap {
+			if reportErr := reportResult(k, v, jiraConfig, jiraClient, trackedIssues, events); reportErr != nil {
+				err = reportErr
+			}
+		}
+	}
+	return
+}
+
+//newJiraClient returns a client for the configured JIRA instance, authenticated as the configured user
+func newJiraClient(jiraConfig JiraConfig) (jiraClient *jira.Client, err error) {
+	jiraClient, err = jira.NewClient(nil, jiraConfig.URL)
+	if err == nil {
+		jiraClient.Authentication.SetBasicAuth(jiraConfig.User, jiraConfig.Password)
+	}
+	return
+}
+
+//reportResult comments on the open JIRA issue for the image (under the specified results map key) with any results
+// it doesn't mention yet, or creates one if there isn't one. If there isn't one because inspectr closed it, it's
+// reopened instead if the config has a ReopenTransition (unless it can't be, e.g. it's been deleted)
+func reportResult(mapKey string, inspectrResults []InspectrResult, jiraConfig JiraConfig, jiraClient *jira.Client,
+	trackedIssues map[string]*JiraIssue, events func(NotifierEvent)) (err error) {
+
+	jiraURL := jiraConfig.URL
+	project := jiraProject(jiraConfig, inspectrResults)
+	summary := summaryFromInspectrMapKey(mapKey)
+	var issues []jira.Issue
 	var resp *jira.Response
-	var err error
-	if len(jiraParamStrings) > 3 {
-		var jiraClient *jira.Client
-		jiraClient, err = jira.NewClient(nil, jiraURL)
-		if err == nil {
-			jiraClient.Authentication.SetBasicAuth(jiraParamStrings[0], jiraParamStrings[1])
-			project := jiraParamStrings[2]
-			issueType := jiraParamStrings[3]
-			var otherFields string
-			if len(jiraParamStrings) > 4 {
-				otherFields = jiraParamStrings[4]
+	issues, resp, err = jiraClient.Issue.Search("summary ~ "+jqlString(summary)+" AND project = "+jqlString(project)+
+		" AND statusCategory != Done", nil)
+	if err == nil {
+		trackedIssue := trackedIssues[mapKey]
+		reopened := false
+		if len(issues) == 0 && trackedIssue != nil && trackedIssue.Closed && jiraConfig.ReopenTransition != "" {
+			reopenErr := transitionIssue(trackedIssue.Key, jiraConfig.ReopenTransition, jiraClient)
+			if reopenErr == nil {
+				reopened = true
+			} else {
+				glog.Warningf("couldn't reopen JIRA issue %s, creating another: %v", trackedIssue.Key, reopenErr)
 			}
-			for k, v := range upgradeMap {
-				summary := summaryFromInspectrMapKey(k)
-				var issues []jira.Issue
-				issues, resp, err = jiraClient.Issue.Search("summary ~ \""+summary+"\""+
-					"AND project = "+project+" AND statusCategory != Done", nil)
-				if err == nil {
-					if len(issues) == 1 {
-						for _, inspectrResult := range v {
-							var issue *jira.Issue
-							issue, resp, err = jiraClient.Issue.Get(issues[0].Key, nil)
-							if err == nil && !resultMentioned(issue, inspectrResult) {
-								addInspectrCommentToIssue(issue.Key, inspectrResult, jiraClient, jiraURL, webhookID)
-							}
-						}
-					} else if len(issues) == 0 {
-						createIssue(project, summary, issueType, otherFields, k, v, jiraClient, jiraURL, webhookID)
-					} else {
-						//TODO: log, there shouldn't be multiple result
-					}
-				}
+		}
+		if len(issues) == 1 {
+			trackIssue(trackedIssues, mapKey, issues[0].Key)
+			err = addInspectrComments(issues[0].Key, mapKey, inspectrResults, jiraClient, jiraURL, events)
+		} else if reopened {
+			trackedIssue.Closed = false
+			events(NotifierEvent{"jira", jiraReopenedEvent, mapKey, jiraURL + "browse/" + trackedIssue.Key})
+			err = addInspectrComments(trackedIssue.Key, mapKey, inspectrResults, jiraClient, jiraURL, events)
+		} else if len(issues) == 0 {
+			var issueKey string
+			issueKey, err = createIssue(project, summary, jiraConfig.IssueType, jiraConfig.Fields, mapKey,
+				inspectrResults, jiraClient, jiraURL, events)
+			if err == nil {
+				trackIssue(trackedIssues, mapKey, issueKey)
+			}
+		} else {
+			//TODO: log, there shouldn't be multiple result
+		}
+	} else {
+		logIfFail(resp, err)
+	}
+	return
+}
+
+//addInspectrComments adds a comment to the JIRA issue for each of the results (for the image under the specified
+// results map key) it doesn't mention yet
+func addInspectrComments(issueKey, mapKey string, inspectrResults []InspectrResult, jiraClient *jira.Client,
+	jiraURL string, events func(NotifierEvent)) (err error) {
+
+	setIssueURL(mapKey, jiraURL+"browse/"+issueKey)
+	for _, inspectrResult := range inspectrResults {
+		var issue *jira.Issue
+		var resp *jira.Response
+		issue, resp, err = jiraClient.Issue.Get(issueKey, nil)
+		if err == nil && !resultMentioned(issue, inspectrResult) {
+			err = addInspectrCommentToIssue(issue.Key, mapKey, inspectrResult, jiraClient, jiraURL, events)
+		} else {
+			logIfFail(resp, err)
+		}
+		if err != nil {
+			break
+		}
+	}
+	return
+}
+
+//trackIssue records the JIRA issue as the open one for the specified results map key, if issues are being tracked
+func trackIssue(trackedIssues map[string]*JiraIssue, mapKey, issueKey string) {
+	if trackedIssues != nil {
+		trackedIssues[mapKey] = &JiraIssue{Key: issueKey}
+	}
+}
+
+//closeIssues closes each open JIRA issue in trackedIssues whose image isn't in the upgradeMap any more, with the
+// config's CloseTransition, unless the image's registry is in registryErrors (so whether it's been upgraded is
+// unknown). Closed issues stay tracked if the config has a ReopenTransition, and are forgotten otherwise. If an issue
+// can't be closed, the others still are, and the last error is returned
+func closeIssues(upgradeMap map[string][]InspectrResult, registryErrors map[string][]string, jiraConfig JiraConfig,
+	trackedIssues map[string]*JiraIssue, events func(NotifierEvent)) (err error) {
+
+	var jiraClient *jira.Client
+	for mapKey, trackedIssue := range trackedIssues {
+		_, upgradable := upgradeMap[mapKey]
+		_, registryErrored := registryErrors[registryFromImage(imageFromInspectrMapKey(mapKey))]
+		if !trackedIssue.Closed && !upgradable && !registryErrored {
+			var closeErr error
+			if jiraClient == nil {
+				jiraClient, closeErr = newJiraClient(jiraConfig)
+			}
+			if closeErr == nil {
+				closeErr = closeIssue(trackedIssue.Key, mapKey, jiraConfig, jiraClient, events)
 			}
-		} else if len(jiraParamStrings) > 0 {
-			err = errors.New("JIRA param strings specified but not enough params found. " +
-				"Usage: user|pass|project|issueType|otherFieldKey:otherFieldValue,otherFieldKey:otherFieldValue...")
+			if closeErr == nil && jiraConfig.ReopenTransition != "" {
+				trackedIssue.Closed = true
+			} else if closeErr == nil {
+				delete(trackedIssues, mapKey)
+			} else {
+				err = closeErr
+			}
+		}
+	}
+	return
+}
+
+//closeIssue comments on the JIRA issue for the image (under the specified results map key) that it's been upgraded,
+// and closes it with the config's CloseTransition, raising a jiraClosedEvent. An issue that's already done (e.g.
+// closed by hand), or has been deleted, is left as it is
+func closeIssue(issueKey, mapKey string, jiraConfig JiraConfig, jiraClient *jira.Client,
+	events func(NotifierEvent)) (err error) {
+
+	var issue *jira.Issue
+	var resp *jira.Response
+	issue, resp, err = jiraClient.Issue.Get(issueKey, nil)
+	if err != nil && resp != nil && resp.StatusCode == http.StatusNotFound {
+		err = nil
+	} else if err == nil && (issue.Fields == nil || issue.Fields.Status == nil ||
+		issue.Fields.Status.StatusCategory.Key != jira.StatusCategoryComplete) {
+
+		var transition string
+		transition, err = transitionID(issueKey, jiraConfig.CloseTransition, jiraClient)
+		if err == nil {
+			_, resp, err = jiraClient.Issue.AddComment(issueKey, &jira.Comment{Body: "inspectr no longer finds " +
+				"upgrades for " + imageFromInspectrMapKey(mapKey) + " in " + podFromInspectrMapKey(mapKey) + "/" +
+				containerFromInspectrMapKey(mapKey) + ": it's been upgraded, or isn't running any more"})
+		}
+		if err == nil {
+			resp, err = jiraClient.Issue.DoTransition(issueKey, transition)
+		}
+		if err == nil {
+			clearIssueURL(mapKey)
+			events(NotifierEvent{"jira", jiraClosedEvent, mapKey, jiraConfig.URL + "browse/" + issueKey})
 		}
 	}
 	logIfFail(resp, err)
+	return
+}
+
+//transitionIssue transitions the JIRA issue with the named transition
+func transitionIssue(issueKey, name string, jiraClient *jira.Client) (err error) {
+	var transition string
+	transition, err = transitionID(issueKey, name, jiraClient)
+	if err == nil {
+		var resp *jira.Response
+		resp, err = jiraClient.Issue.DoTransition(issueKey, transition)
+		logIfFail(resp, err)
+	}
+	return
+}
+
+//transitionID returns the id of the JIRA issue's transition with the specified name (ignoring case), returning an
+// error if the issue's current status doesn't have one
+func transitionID(issueKey, name string, jiraClient *jira.Client) (id string, err error) {
+	var transitions []jira.Transition
+	var resp *jira.Response
+	transitions, resp, err = jiraClient.Issue.GetTransitions(issueKey)
+	if err == nil {
+		for _, transition := range transitions {
+			if strings.EqualFold(transition.Name, name) {
+				id = transition.ID
+			}
+		}
+		if id == "" {
+			err = errors.New("JIRA issue " + issueKey + " has no " + strconv.Quote(name) + " transition")
+		}
+	} else {
+		logIfFail(resp, err)
+	}
+	return
+}
+
+//jqlString returns the specified string as a quoted JQL string, so that a value from an annotation, e.g. a project
+// key, can't change what a query matches
+func jqlString(str string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(str) + `"`
+}
+
+//jiraProject returns the JIRA project issues for the specified results should be in: the one their owners want (from
+// the inspectr.io/jira-project annotation), or else the configured one
+func jiraProject(jiraConfig JiraConfig, inspectrResults []InspectrResult) (project string) {
+	project = jiraConfig.Project
+	for _, inspectrResult := range inspectrResults {
+		if inspectrResult.Ownership.JiraProject != "" {
+			project = inspectrResult.Ownership.JiraProject
+			break
+		}
+	}
+	return
 }
 
 //resultMentioned returns a boolean indicating whether the specified InspectrResult
@@ -950,14 +1517,17 @@ func stringContainsInspectrResult(commentOrDescString string, inspectrResult Ins
 	return
 }
 
-//addInspectrCommentToIssue adds a comment to the JIRA specified, based on the InspectrResult.
-// It doesn't return anything
-func addInspectrCommentToIssue(issueKey string, inspectrResult InspectrResult, jiraClient *jira.Client, jiraURL, webhookID string) {
+//addInspectrCommentToIssue adds a comment to the JIRA specified, based on the InspectrResult, and raises a
+// jiraCommentedEvent
+func addInspectrCommentToIssue(issueKey, mapKey string, inspectrResult InspectrResult, jiraClient *jira.Client,
+	jiraURL string, events func(NotifierEvent)) (err error) {
+
 	_, resp, err := jiraClient.Issue.AddComment(issueKey, commentFromInspectrResult(inspectrResult))
 	if err == nil {
-		postStringToSlack("just commented on "+jiraURL+"browse/"+issueKey, webhookID)
+		events(NotifierEvent{"jira", jiraCommentedEvent, mapKey, jiraURL + "browse/" + issueKey})
 	}
 	logIfFail(resp, err)
+	return
 }
 
 //upgradesString returns a comma sep string of the upgrade versions, prefixed by "Upgrades: "
@@ -997,6 +1567,17 @@ func commentFromInspectrResult(inspectrResult InspectrResult) (comment *jira.Com
 	buffer.WriteString("Version: ")
 	buffer.WriteString(inspectrResult.Version)
 	buffer.WriteString(newLineString)
+	buffer.WriteString("VersionsBehind: ")
+	buffer.WriteString(strconv.Itoa(inspectrResult.VersionsBehind))
+	buffer.WriteString(newLineString)
+	buffer.WriteString("DaysBehind: ")
+	buffer.WriteString(daysBehindString(inspectrResult))
+	buffer.WriteString(newLineString)
+	if inspectrResult.Ownership.Team != "" {
+		buffer.WriteString("Owner: ")
+		buffer.WriteString(inspectrResult.Ownership.Team)
+		buffer.WriteString(newLineString)
+	}
 	buffer.WriteString("{code}")
 	comment = new(jira.Comment)
 	comment.Body = buffer.String()
@@ -1027,18 +1608,20 @@ func infraDetailsString(mapkey string) (infraDetailsString string) {
 	return
 }
 
-//createIssue creates a new JIRA issue with the necessary fields/summary/desc.
-// It doesn't return anything.
-func createIssue(project, summary, issueType, otherFields, mapKey string,
+//createIssue creates a new JIRA issue with the necessary fields/summary/desc, raises a jiraCreatedEvent, and returns
+// its key
+func createIssue(project, summary, issueType string, otherFields map[string]string, mapKey string,
 	inspectrResults []InspectrResult, jiraClient *jira.Client,
-	jiraURL, webhookID string) {
-	var err error
+	jiraURL string, events func(NotifierEvent)) (issueKey string, err error) {
 	var resp *jira.Response
 	var meta *jira.CreateMetaInfo
 	meta, resp, err = jiraClient.Issue.GetCreateMeta(project)
+	var metaProject *jira.MetaProject
+	var metaIssuetype *jira.MetaIssueType
+	if err == nil {
+		metaProject, metaIssuetype, err = issueTypeMeta(meta, project, issueType)
+	}
 	if err == nil {
-		metaProject := meta.GetProjectWithKey(project)
-		metaIssuetype := metaProject.GetIssueTypeWithName(issueType)
 		fieldsConfig := make(map[string]string, 0)
 		var buffer bytes.Buffer
 		buffer.WriteString(infraDetailsString(mapKey))
@@ -1049,12 +1632,13 @@ func createIssue(project, summary, issueType, otherFields, mapKey string,
 		fieldsConfig["Issue Type"] = issueType
 		fieldsConfig["Description"] = buffer.String()
 		fieldsConfig["Project"] = project
-		if otherFields != "" {
-			for _, otherField := range strings.Split(otherFields, ",") {
-				keyValueStrings := strings.Split(otherField, ":")
-				if len(keyValueStrings) == 2 {
-					fieldsConfig[keyValueStrings[0]] = keyValueStrings[1]
-				}
+		for k, v := range otherFields {
+			fieldsConfig[k] = v
+		}
+		for _, inspectrResult := range inspectrResults {
+			if component := inspectrResult.Ownership.JiraComponent; component != "" {
+				fieldsConfig["Component/s"] = component
+				break
 			}
 		}
 		var issue *jira.Issue
@@ -1062,11 +1646,32 @@ func createIssue(project, summary, issueType, otherFields, mapKey string,
 		if err == nil {
 			issue, resp, err = jiraClient.Issue.Create(issue)
 			if err == nil {
-				postStringToSlack("just created "+jiraURL+"browse/"+issue.Key, webhookID)
+				issueKey = issue.Key
+				setIssueURL(mapKey, jiraURL+"browse/"+issue.Key)
+				events(NotifierEvent{"jira", jiraCreatedEvent, mapKey, jiraURL + "browse/" + issue.Key})
 			}
 		}
 	}
 	logIfFail(resp, err)
+	return
+}
+
+//issueTypeMeta returns the create metadata for the specified project and issue type, or an error if either isn't in
+// meta, e.g. because an annotation names a project that doesn't exist
+func issueTypeMeta(meta *jira.CreateMetaInfo, project, issueType string) (metaProject *jira.MetaProject,
+	metaIssuetype *jira.MetaIssueType, err error) {
+	metaProject = meta.GetProjectWithKey(project)
+	if metaProject == nil {
+		err = errors.New("JIRA project " + strconv.Quote(project) + " doesn't exist, or inspectr can't create issues " +
+			"in it")
+	} else {
+		metaIssuetype = metaProject.GetIssueTypeWithName(issueType)
+		if metaIssuetype == nil {
+			err = errors.New("JIRA project " + strconv.Quote(project) + " has no " + strconv.Quote(issueType) +
+				" issue type")
+		}
+	}
+	return
 }
 
 //logIfFail outputs to glog if err is not nil, also adding a response string if that's not nil
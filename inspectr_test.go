@@ -1,6 +1,13 @@
 package main
 
 import (
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
 	"testing"
 	"time"
 
@@ -37,23 +44,6 @@ func TestV2Tag(t *testing.T) {
 	}
 }
 
-var sleepingTimes = []struct {
-	withinWindow bool
-	expected     int
-}{
-	{true, 360},
-	{false, 60},
-}
-
-func TestSleepTime(t *testing.T) {
-	for _, sleepingTime := range sleepingTimes {
-		if v := sleepTime(sleepingTime.withinWindow); v != sleepingTime.expected {
-			t.Errorf("sleepTime(%t) returned %d, expected %d",
-				sleepingTime.withinWindow, v, sleepingTime.expected)
-		}
-	}
-}
-
 var podnames = []struct {
 	fullpodname string
 	podname     string
@@ -202,26 +192,6 @@ func TestContainerFromInspectrMapKey(t *testing.T) {
 	}
 }
 
-var locationStrings = []struct {
-	locationExpected string
-	locationActual   string
-}{
-	{"Europe/London", "Europe/London"},
-	{"Europe/banana", "Local"},
-	{"Local", "Local"},
-	{"", "UTC"},
-	{"UTC", "UTC"},
-}
-
-func TestLocation(t *testing.T) {
-	for _, locationString := range locationStrings {
-		if v := location(locationString.locationExpected).String(); v != locationString.locationActual {
-			t.Errorf("location(%s).String() returned %s, expected %s",
-				locationString.locationExpected, v, locationString.locationActual)
-		}
-	}
-}
-
 var resultMentionedVars = []struct {
 	commentBody             string
 	inspectrResultName      string
@@ -309,33 +279,435 @@ func TestContains(t *testing.T) {
 	}
 }
 
-var timevars = []struct {
-	scheduleStrings []string
-	isWeekly        bool
-	hour            int
-	min             int
+var latestVersions = []struct {
+	versionStrings []string
+	latest         string
+}{
+	{[]string{"v0.0.2", "v0.0.10", "v0.0.3"}, "v0.0.10"},
+	{[]string{"1.2.0", "banana", "1.10.0"}, "1.10.0"},
+	{[]string{"banana"}, ""},
+	{nil, ""},
+}
+
+func TestLatestVersion(t *testing.T) {
+	for _, latestVersionVar := range latestVersions {
+		if v := latestVersion(latestVersionVar.versionStrings); v != latestVersionVar.latest {
+			t.Errorf("latestVersion(%v) returned %s, expected %s",
+				latestVersionVar.versionStrings, v, latestVersionVar.latest)
+		}
+	}
+}
+
+var daysBetweenVars = []struct {
+	from time.Time
+	to   time.Time
+	days int
+}{
+	{time.Date(2017, 6, 1, 0, 0, 0, 0, time.UTC), time.Date(2017, 6, 11, 0, 0, 0, 0, time.UTC), 10},
+	{time.Date(2017, 6, 1, 0, 0, 0, 0, time.UTC), time.Date(2017, 6, 1, 23, 0, 0, 0, time.UTC), 0},
+	{time.Date(2017, 6, 11, 0, 0, 0, 0, time.UTC), time.Date(2017, 6, 1, 0, 0, 0, 0, time.UTC), 0},
+}
+
+func TestDaysBetween(t *testing.T) {
+	for _, daysBetweenVar := range daysBetweenVars {
+		if v := daysBetween(daysBetweenVar.from, daysBetweenVar.to); v != daysBetweenVar.days {
+			t.Errorf("daysBetween(%s, %s) returned %d, expected %d",
+				daysBetweenVar.from, daysBetweenVar.to, v, daysBetweenVar.days)
+		}
+	}
+}
+
+func TestDecodeGcrTagCreated(t *testing.T) {
+	gcrJSON := `{"manifest":{"sha256:abc":{"imageSizeBytes":"1","tag":["v0.0.1"],
+		"timeCreatedMs":"1496275200000","timeUploadedMs":"1496275200000"}},
+		"name":"eversc/inspectr","tags":["v0.0.1","v0.0.2"]}`
+	gcrTags, err := decodeGcrTag(strings.NewReader(gcrJSON))
+	if err != nil {
+		t.Fatalf("decodeGcrTag returned error %s", err)
+	}
+	expected := time.Date(2017, 6, 1, 0, 0, 0, 0, time.UTC)
+	if len(gcrTags) != 2 || !gcrTags[0].created().Equal(expected) || !gcrTags[1].created().IsZero() {
+		t.Errorf("decodeGcrTag returned %+v, expected v0.0.1 created at %s and v0.0.2 unknown",
+			gcrTags, expected)
+	}
+}
+
+var daysBehindStrings = []struct {
+	daysBehind int
+	expected   string
+}{
+	{-1, "?"},
+	{0, "0"},
+	{42, "42"},
+}
+
+func TestDaysBehindString(t *testing.T) {
+	for _, daysBehindVar := range daysBehindStrings {
+		var inspectrResult InspectrResult
+		inspectrResult.DaysBehind = daysBehindVar.daysBehind
+		if v := daysBehindString(inspectrResult); v != daysBehindVar.expected {
+			t.Errorf("daysBehindString(%+v) returned %s, expected %s", inspectrResult, v,
+				daysBehindVar.expected)
+		}
+	}
+}
+
+var upgradeClasses = []struct {
+	currentVersion string
+	latestVersion  string
+	class          string
+}{
+	{"v1.2.3", "v2.0.0", "major"},
+	{"v1.2.3", "v1.3.0", "minor"},
+	{"v1.2.3", "v1.2.4", "patch"},
+	{"1.2", "1.2.1", "patch"},
+	{"1.2.0-alpha", "1.2.0", "patch"},
+	{"banana", "1.2.0", "unknown"},
+	{"1.2.0", "", "unknown"},
+}
+
+func TestUpgradeClass(t *testing.T) {
+	for _, upgradeClassVar := range upgradeClasses {
+		if v := upgradeClass(upgradeClassVar.currentVersion, upgradeClassVar.latestVersion); v != upgradeClassVar.class {
+			t.Errorf("upgradeClass(%s, %s) returned %s, expected %s", upgradeClassVar.currentVersion,
+				upgradeClassVar.latestVersion, v, upgradeClassVar.class)
+		}
+	}
+}
+
+var registries = []struct {
+	imageString string
+	registry    string
+}{
+	{"gcr.io/google_containers/nginx-ingress-controller", "gcr.io"},
+	{"quay.io/coreos/etcd", "quay.io"},
+	{"registry.opensource.zalan.do/teapot/external-dns", "zalan.do"},
+	{"eversc/inspectr", "registry.hub.docker.com"},
+}
+
+func TestRegistryFromImage(t *testing.T) {
+	for _, registryVar := range registries {
+		if v := registryFromImage(registryVar.imageString); v != registryVar.registry {
+			t.Errorf("registryFromImage(%s) returned %s, expected %s", registryVar.imageString, v,
+				registryVar.registry)
+		}
+	}
+}
+
+var namespaceFilters = []struct {
+	include   []string
+	ignore    []string
+	namespace string
+	included  bool
+}{
+	{nil, []string{"kube-system"}, "default", true},
+	{nil, []string{"kube-system"}, "kube-system", false},
+	{nil, []string{"kube-*"}, "kube-public", false},
+	{[]string{"team-*"}, nil, "team-payments", true},
+	{[]string{"team-*"}, nil, "default", false},
+	{[]string{"team-*"}, []string{"team-*-dev"}, "team-payments-dev", false},
+	{[]string{"team-*", "default"}, []string{"team-*-dev"}, "default", true},
+}
+
+func TestNamespaceIncluded(t *testing.T) {
+	defer func(include, ignore []string) {
+		includeNamespaces, ignoreNamespaces = include, ignore
+	}(includeNamespaces, ignoreNamespaces)
+	for _, namespaceFilter := range namespaceFilters {
+		includeNamespaces, ignoreNamespaces = namespaceFilter.include, namespaceFilter.ignore
+		if v := namespaceIncluded(namespaceFilter.namespace); v != namespaceFilter.included {
+			t.Errorf("namespaceIncluded(%s) with include %v, ignore %v returned %t, expected %t",
+				namespaceFilter.namespace, namespaceFilter.include, namespaceFilter.ignore, v,
+				namespaceFilter.included)
+		}
+	}
+}
+
+//fakePodsHandler serves pods in the a, b and c namespaces, of which only a is labelled team=payments, and forbids
+// listing pods in the forbidden namespace
+func fakePodsHandler(w http.ResponseWriter, r *http.Request) {
+	podsJSON := func(namespaces ...string) string {
+		var items []string
+		for _, namespace := range namespaces {
+			items = append(items, `{"metadata":{"name":"app-1234-abcd","namespace":"`+namespace+
+				`","labels":{"pod-template-hash":"5d8f7b9c6"}}}`)
+		}
+		return `{"items":[` + strings.Join(items, ",") + `]}`
+	}
+	switch r.URL.Path {
+	case "/api/v1/pods":
+		if r.URL.Query().Get("labelSelector") == "tier=web" {
+			w.Write([]byte(podsJSON("a", "c")))
+		} else {
+			w.Write([]byte(podsJSON("a", "b", "c")))
+		}
+	case "/api/v1/namespaces/a/pods", "/api/v1/namespaces/b/pods":
+		w.Write([]byte(podsJSON(strings.Split(r.URL.Path, "/")[4])))
+	case "/api/v1/namespaces":
+		if r.URL.Query().Get("labelSelector") == "team=payments" {
+			w.Write([]byte(`{"items":[{"metadata":{"name":"a","labels":{"team":"payments"}}}]}`))
+		} else {
+			w.Write([]byte(`{"items":[]}`))
+		}
+	default:
+		http.Error(w, `{"reason":"Forbidden"}`, http.StatusForbidden)
+	}
+}
+
+var clusterPodsVars = []struct {
+	namespaces        []string
+	podSelector       string
+	namespaceSelector string
+	podNamespaces     []string
+	valid             bool
 }{
-	{[]string{""}, false, 10, 0},
-	{[]string{""}, true, 10, 0},
-	{[]string{"1234567"}, false, 10, 0},
-	{[]string{"1234567"}, true, 10, 0},
-	{[]string{"1430"}, false, 14, 30},
-	{[]string{"1430"}, true, 10, 0},
-	{[]string{"TUESDAY", "1430"}, false, 10, 0},
-	{[]string{"TUESDAY", "1430"}, true, 14, 30},
-	{[]string{"banana", "1430"}, true, 14, 30},
-	{[]string{"apples"}, false, 10, 0},
-	{[]string{"apples"}, true, 10, 0},
-	{[]string{"4830"}, false, 0, 30},
-}
-
-func TestTimeFromSchedule(t *testing.T) {
-	loc, _ := time.LoadLocation("Local")
-	for _, timevar := range timevars {
-		now := time.Now()
-		if v := timeFromSchedule(timevar.scheduleStrings, timevar.isWeekly, now, loc); v.Hour() != timevar.hour || v.Minute() != timevar.min {
-			t.Errorf("timeFromSchedule(%v, %t, %s, %s) returned hour:%d min:%d, expected hour:%d min:%d",
-				timevar.scheduleStrings, timevar.isWeekly, now, loc, v.Hour(), v.Minute(), timevar.hour, timevar.min)
+	{nil, "", "", []string{"a", "b", "c"}, true},
+	{nil, "tier=web", "", []string{"a", "c"}, true},
+	{nil, "", "team=payments", []string{"a"}, true},
+	{[]string{"a", "b"}, "", "", []string{"a", "b"}, true},
+	{[]string{"a", "forbidden"}, "", "", nil, false},
+}
+
+func TestClusterPods(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(fakePodsHandler))
+	defer server.Close()
+	api := &K8sAPI{BaseURL: server.URL, Client: http.DefaultClient}
+	defer func(pod, namespace string) {
+		podSelector, namespaceSelector = pod, namespace
+	}(podSelector, namespaceSelector)
+	for _, clusterPodsVar := range clusterPodsVars {
+		podSelector, namespaceSelector = clusterPodsVar.podSelector, clusterPodsVar.namespaceSelector
+		pods, _, err := clusterPods(api, clusterPodsVar.namespaces)
+		var podNamespaces []string
+		if pods != nil {
+			for _, item := range pods.Items {
+				podNamespaces = append(podNamespaces, item.Metadata.Namespace)
+			}
+		}
+		if (err == nil) != clusterPodsVar.valid || !reflect.DeepEqual(podNamespaces, clusterPodsVar.podNamespaces) {
+			t.Errorf("clusterPods(%v) with selectors %s, %s returned pods in %v, error %v, expected %v",
+				clusterPodsVar.namespaces, clusterPodsVar.podSelector, clusterPodsVar.namespaceSelector,
+				podNamespaces, err, clusterPodsVar.podNamespaces)
+		}
+	}
+}
+
+func TestClusterPodsNamespacesUnlistable(t *testing.T) {
+	listable := true
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/api/v1/namespaces" {
+			if !listable {
+				w.WriteHeader(http.StatusForbidden)
+			} else {
+				w.Write([]byte(`{"items":[{"metadata":{"name":"b","annotations":{"inspectr.io/exclude":"true"}}}]}`))
+			}
+			return
+		}
+		fakePodsHandler(w, r)
+	}))
+	defer server.Close()
+	api := &K8sAPI{BaseURL: server.URL, Client: http.DefaultClient}
+	if _, namespaceMetadata, err := clusterPods(api, nil); err != nil || len(namespaceMetadata) != 1 {
+		t.Fatalf("clusterPods returned namespaces %+v, %v, expected b", namespaceMetadata, err)
+	}
+	//losing RBAC to list namespaces keeps b opted out
+	listable = false
+	for i := 0; i < 2; i++ {
+		pods, namespaceMetadata, err := clusterPods(api, nil)
+		if err != nil || len(pods.Items) != 3 || namespaceMetadata["b"].Annotations[excludeAnnotation] != "true" {
+			t.Errorf("clusterPods %d without RBAC to list namespaces returned %+v, %v, %v, expected every pod and "+
+				"the namespaces last listed", i, pods, namespaceMetadata, err)
+		}
+		if !namespacesUnlistable[server.URL] {
+			t.Errorf("clusterPods %d didn't record that namespaces can't be listed, so would warn again", i)
+		}
+	}
+	delete(lastNamespaceMetadata, server.URL)
+	if _, namespaceMetadata, err := clusterPods(api, nil); err != nil || namespaceMetadata != nil {
+		t.Errorf("clusterPods without namespaces ever listed returned %+v, %v, expected no namespaces",
+			namespaceMetadata, err)
+	}
+}
+
+func TestImageToResultsMapAnnotations(t *testing.T) {
+	pods, err := decodeData(strings.NewReader(`{"items":[
+		{"metadata":{"name":"app-1234-abcd","namespace":"a"},"status":{"phase":"Running"},
+			"spec":{"containers":[{"name":"app","image":"eversc/app:v1.0.0"}]}},
+		{"metadata":{"name":"web-1234-abcd","namespace":"b","annotations":{"inspectr.io/owner":"web"}},
+			"status":{"phase":"Running"},"spec":{"containers":[{"name":"web","image":"eversc/web:v1.0.0"}]}},
+		{"metadata":{"name":"db-1234-abcd","namespace":"b","annotations":{"inspectr.io/exclude":"true"}},
+			"status":{"phase":"Running"},"spec":{"containers":[{"name":"db","image":"eversc/db:v1.0.0"}]}}]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	namespaces := map[string]NamespaceMetadata{
+		"a": {Name: "a", Annotations: map[string]string{excludeAnnotation: "true"}},
+		"b": {Name: "b", Annotations: map[string]string{ownerAnnotation: "b-team", slackChannelAnnotation: "#b"}},
+	}
+	resultsMap := imageToResultsMap(pods, namespaces, "project", "cluster")
+	results, ok := resultsMap["project:cluster:eversc/web:web:web"]
+	if len(resultsMap) != 1 || !ok || results[0].Ownership != (Ownership{Team: "web", SlackChannel: "#b"}) {
+		t.Errorf("imageToResultsMap returned %#v, expected only eversc/web, owned by web", resultsMap)
+	}
+}
+
+//fakeJira type: a JIRA api serving issues with the statuses in statusCategories, each with a "Done" and a "Reopen"
+// transition, and recording the searches, comments and transitions made. It has no projects to create issues in
+type fakeJira struct {
+	statusCategories map[string]string
+	searches         []string
+	comments         []string
+	transitions      []string
+}
+
+func (fake *fakeJira) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	path := strings.Split(strings.TrimPrefix(r.URL.Path, "/rest/api/2/"), "/")
+	switch {
+	case path[0] == "search":
+		fake.searches = append(fake.searches, r.URL.Query().Get("jql"))
+		w.Write([]byte(`{"issues": []}`))
+	case path[0] == "issue" && path[1] == "createmeta":
+		w.Write([]byte(`{"projects": []}`))
+	case path[0] != "issue" || fake.statusCategories[path[1]] == "":
+		w.WriteHeader(http.StatusNotFound)
+	case len(path) == 2:
+		statusCategory := map[string]string{"key": fake.statusCategories[path[1]]}
+		json.NewEncoder(w).Encode(map[string]interface{}{"key": path[1], "fields": map[string]interface{}{
+			"status": map[string]interface{}{"statusCategory": statusCategory}}})
+	case path[2] == "transitions" && r.Method == "POST":
+		var payload struct {
+			Transition struct{ ID string } `json:"transition"`
 		}
+		json.NewDecoder(r.Body).Decode(&payload)
+		fake.transitions = append(fake.transitions, path[1]+" "+payload.Transition.ID)
+		fake.statusCategories[path[1]] = map[string]string{"31": jira.StatusCategoryComplete,
+			"11": jira.StatusCategoryToDo}[payload.Transition.ID]
+		w.WriteHeader(http.StatusNoContent)
+	case path[2] == "transitions":
+		w.Write([]byte(`{"transitions": [{"id": "31", "name": "Done"}, {"id": "11", "name": "Reopen"}]}`))
+	case path[2] == "comment":
+		var comment jira.Comment
+		json.NewDecoder(r.Body).Decode(&comment)
+		fake.comments = append(fake.comments, path[1]+" "+comment.Body)
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte(`{"id": "1"}`))
+	}
+}
+
+func TestJiraCloseAndReopen(t *testing.T) {
+	fake := &fakeJira{statusCategories: map[string]string{"INS-1": jira.StatusCategoryInProgress,
+		"INS-2": jira.StatusCategoryToDo, "INS-3": jira.StatusCategoryToDo, "INS-4": jira.StatusCategoryComplete}}
+	server := httptest.NewServer(fake)
+	defer server.Close()
+	jiraConfig := JiraConfig{URL: server.URL + "/", Project: "INS", CloseTransition: "done",
+		ReopenTransition: "Reopen"}
+	appKey := "project:jira:eversc/app:app:app"
+	dbKey := "project:jira:quay.io/eversc/db:db:db"
+	webKey := "project:jira:eversc/web:web:web"
+	trackedIssues := map[string]*JiraIssue{appKey: {Key: "INS-1"}, dbKey: {Key: "INS-2"}, webKey: {Key: "INS-3"},
+		"project:jira:eversc/old:old:old": {Key: "INS-4"}, "project:jira:eversc/gone:gone:gone": {Key: "INS-5"}}
+	var events []NotifierEvent
+	recordEvent := func(event NotifierEvent) { events = append(events, event) }
+	//the app has been upgraded, the db's registry couldn't be reached, and the web image still has upgrades
+	upgradeMap := map[string][]InspectrResult{webKey: {{Name: "web", Namespace: "default", Upgrades: []string{"v2"}}}}
+	registryErrors := map[string][]string{registryFromImage("quay.io/eversc/db"): {"quay.io is down"}}
+	if err := closeIssues(upgradeMap, registryErrors, jiraConfig, trackedIssues, recordEvent); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(fake.transitions, []string{"INS-1 31"}) || len(fake.comments) != 1 ||
+		!strings.HasPrefix(fake.comments[0], "INS-1 inspectr no longer finds upgrades for eversc/app") {
+		t.Errorf("closing made transitions %v and comments %q, expected just INS-1 closed", fake.transitions,
+			fake.comments)
+	}
+	if len(events) != 1 || events[0].Kind != jiraClosedEvent || events[0].URL != server.URL+"/browse/INS-1" {
+		t.Errorf("closing raised events %+v, expected INS-1 closed", events)
+	}
+	if v := issueURL(appKey); v != "" {
+		t.Errorf("closed issue's url %s is still linked to", v)
+	}
+	for mapKey, closed := range map[string]bool{appKey: true, dbKey: false, webKey: false,
+		"project:jira:eversc/old:old:old": true, "project:jira:eversc/gone:gone:gone": true} {
+		if trackedIssues[mapKey].Closed != closed {
+			t.Errorf("tracked issue %s is closed: %t, expected %t", trackedIssues[mapKey].Key, !closed, closed)
+		}
+	}
+	//the app has regressed, so its issue is reopened and commented on, rather than another created
+	events = nil
+	upgradeMap = map[string][]InspectrResult{appKey: {{Name: "app", Namespace: "default", Upgrades: []string{"v3"}}}}
+	if err := reportResults(upgradeMap, jiraConfig, trackedIssues, recordEvent); err != nil {
+		t.Fatal(err)
+	}
+	if fake.transitions[len(fake.transitions)-1] != "INS-1 11" || trackedIssues[appKey].Closed {
+		t.Errorf("regression made transitions %v, expected INS-1 reopened", fake.transitions)
+	}
+	if len(events) != 2 || events[0].Kind != jiraReopenedEvent || events[1].Kind != jiraCommentedEvent {
+		t.Errorf("regression raised events %+v, expected INS-1 reopened and commented on", events)
+	}
+	if v := issueURL(appKey); v != server.URL+"/browse/INS-1" {
+		t.Errorf("reopened issue's url is %s, expected INS-1's", v)
+	}
+	//without a reopen transition, closed issues are forgotten
+	jiraConfig.ReopenTransition = ""
+	if err := closeIssues(nil, nil, jiraConfig, trackedIssues, recordEvent); err != nil {
+		t.Fatal(err)
+	}
+	if len(trackedIssues) != 2 {
+		t.Errorf("tracked issues are %+v, expected only the ones closed with a reopen transition", trackedIssues)
+	}
+}
+
+func TestJiraUnknownProject(t *testing.T) {
+	fake := &fakeJira{}
+	server := httptest.NewServer(fake)
+	defer server.Close()
+	jiraConfig := JiraConfig{URL: server.URL + "/", Project: "INS", IssueType: "Task"}
+	upgradeMap := map[string][]InspectrResult{"project:jira:eversc/app:app:app": {{Name: "app", Namespace: "default",
+		Upgrades: []string{"v2"}, Ownership: Ownership{JiraProject: `NOPE" OR project = "INS`}}}}
+	err := reportResults(upgradeMap, jiraConfig, make(map[string]*JiraIssue), func(NotifierEvent) {})
+	if err == nil || !strings.Contains(err.Error(), `JIRA project "NOPE\" OR project = \"INS" doesn't exist`) {
+		t.Errorf("reporting to an unknown project returned %v, expected it to be named in an error", err)
+	}
+	expected := "summary ~ " + jqlString(summaryFromInspectrMapKey("project:jira:eversc/app:app:app")) +
+		` AND project = "NOPE\" OR project = \"INS" AND statusCategory != Done`
+	if len(fake.searches) != 1 || fake.searches[0] != expected {
+		t.Errorf("reporting searched for %q, expected %q", fake.searches, expected)
+	}
+}
+
+func TestJiraRateLimitedRegistryKeepsIssueOpen(t *testing.T) {
+	registry := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTooManyRequests)
+	}))
+	defer registry.Close()
+	//registries are always https, and map keys can't have a port in them, so registry.example.com is dialled as the
+	// fake registry
+	transport := registry.Client().Transport.(*http.Transport).Clone()
+	transport.DialContext = func(ctx context.Context, network, _ string) (net.Conn, error) {
+		return (&net.Dialer{}).DialContext(ctx, network, registry.Listener.Addr().String())
+	}
+	defer func(transport http.RoundTripper) { http.DefaultTransport = transport }(http.DefaultTransport)
+	http.DefaultTransport = transport
+	host := "registry.example.com"
+	defer func(registries []RegistryConfig) { registryConfigs = registries }(registryConfigs)
+	registryConfigs = []RegistryConfig{{Host: host, API: "v2"}}
+	fake := &fakeJira{statusCategories: map[string]string{"INS-1": jira.StatusCategoryToDo}}
+	server := httptest.NewServer(fake)
+	defer server.Close()
+	jiraConfig := JiraConfig{URL: server.URL + "/", Project: "INS", CloseTransition: "Done"}
+	appKey := "project:jira:" + host + "/eversc/app:app:app"
+	trackedIssues := map[string]*JiraIssue{appKey: {Key: "INS-1"}}
+	upgradeMap, registryErrors := upgradesMap(map[string][]InspectrResult{appKey: {{Name: "app",
+		Namespace: "default", Version: "v1"}}})
+	if len(upgradeMap) != 0 || len(registryErrors[host]) != 1 ||
+		!strings.Contains(registryErrors[host][0], "bad status code (429)") {
+		t.Fatalf("upgradesMap with a rate limited registry returned %v, %v, expected a 429 error for %s", upgradeMap,
+			registryErrors, host)
+	}
+	if err := closeIssues(upgradeMap, registryErrors, jiraConfig, trackedIssues, func(NotifierEvent) {}); err != nil {
+		t.Fatal(err)
+	}
+	if len(fake.transitions) != 0 || trackedIssues[appKey].Closed {
+		t.Errorf("closing made transitions %v, expected INS-1 left open while its registry is rate limited",
+			fake.transitions)
 	}
 }
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"bytes"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"time"
+)
+
+//K8sAPI type representing a connection to the k8s master's REST api
+type K8sAPI struct {
+	BaseURL string
+	Token   string
+	Client  *http.Client
+}
+
+//inClusterK8sAPI returns a K8sAPI that talks to the master of the cluster inspectr is running in, authenticating as
+// the pod's service account
+func inClusterK8sAPI() (api *K8sAPI, err error) {
+	api, err = newK8sAPI("https://kubernetes.default", "/var/run/secrets/kubernetes.io/serviceaccount/token",
+		"/var/run/secrets/kubernetes.io/serviceaccount/ca.crt")
+	return
+}
+
+//clusterK8sAPI returns a K8sAPI that talks to the master of the specified cluster, which is the cluster inspectr is
+// running in if it has no APIServer
+func clusterK8sAPI(cluster ClusterConfig) (api *K8sAPI, err error) {
+	if cluster.APIServer == "" {
+		api, err = inClusterK8sAPI()
+	} else {
+		api, err = newK8sAPI(strings.TrimSuffix(cluster.APIServer, "/"), cluster.TokenFile, cluster.CAFile)
+	}
+	return
+}
+
+//newK8sAPI returns a K8sAPI that talks to the master at the specified url, authenticating with the token in the
+// specified file. The master's certificate is verified against the CA in caFile, or the system's CAs if it's empty
+func newK8sAPI(baseURL, tokenFile, caFile string) (api *K8sAPI, err error) {
+	var caCertPool *x509.CertPool
+	if caFile != "" {
+		var caCert []byte
+		caCert, err = ioutil.ReadFile(caFile)
+		if err == nil {
+			caCertPool = x509.NewCertPool()
+			caCertPool.AppendCertsFromPEM(caCert)
+		}
+	}
+	if err == nil {
+		var token []byte
+		token, err = ioutil.ReadFile(tokenFile)
+		if err == nil {
+			api = &K8sAPI{
+				BaseURL: baseURL,
+				Token:   strings.TrimSpace(string(token)),
+				Client: &http.Client{
+					Transport: &http.Transport{
+						TLSClientConfig: &tls.Config{
+							RootCAs: caCertPool,
+						},
+					},
+					Timeout: 30 * time.Second,
+				},
+			}
+		}
+	}
+	return
+}
+
+//do fires off a request to the specified api path, json encoding the body if it isn't nil
+func (api *K8sAPI) do(method, path string, body interface{}) (resp *http.Response, err error) {
+	var bodyReader io.Reader
+	if body != nil {
+		bodyBuff := new(bytes.Buffer)
+		err = json.NewEncoder(bodyBuff).Encode(body)
+		bodyReader = bodyBuff
+	}
+	if err == nil {
+		var req *http.Request
+		req, err = http.NewRequest(method, api.BaseURL+path, bodyReader)
+		if err == nil {
+			req.Header.Set("Authorization", "Bearer "+api.Token)
+			if body != nil {
+				contentType := "application/json"
+				if method == "PATCH" {
+					contentType = "application/merge-patch+json"
+				}
+				req.Header.Set("Content-Type", contentType)
+			}
+			resp, err = api.Client.Do(req)
+		}
+	}
+	return
+}
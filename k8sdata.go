@@ -10,18 +10,13 @@ type Data struct {
 	APIVersion string `json:"apiVersion"`
 	Items      []struct {
 		Metadata struct {
-			Annotations struct {
-				KubernetesIoCreatedBy string `json:"kubernetes.io/created-by"`
-			} `json:"annotations"`
-			CreationTimestamp time.Time `json:"creationTimestamp"`
-			GenerateName      string    `json:"generateName"`
-			Labels            struct {
-				App             string `json:"app"`
-				PodTemplateHash int64  `json:"pod-template-hash,string"`
-			} `json:"labels"`
-			Name            string `json:"name"`
-			Namespace       string `json:"namespace"`
-			OwnerReferences []struct {
+			Annotations       map[string]string `json:"annotations"`
+			CreationTimestamp time.Time         `json:"creationTimestamp"`
+			GenerateName      string            `json:"generateName"`
+			Labels            map[string]string `json:"labels"`
+			Name              string            `json:"name"`
+			Namespace         string            `json:"namespace"`
+			OwnerReferences   []struct {
 				APIVersion         string `json:"apiVersion"`
 				BlockOwnerDeletion bool   `json:"blockOwnerDeletion"`
 				Controller         bool   `json:"controller"`
@@ -129,3 +124,10 @@ type Data struct {
 		SelfLink        string `json:"selfLink"`
 	} `json:"metadata"`
 }
+
+//NamespaceList type representing the json schema of https://[master]/api/v1/namespaces
+type NamespaceList struct {
+	Items []struct {
+		Metadata NamespaceMetadata `json:"metadata"`
+	} `json:"items"`
+}
@@ -0,0 +1,204 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/golang/glog"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+//LeaderElector type representing this inspectr's candidacy for leadership, held via a k8s Lease
+type LeaderElector struct {
+	api           *K8sAPI
+	namespace     string
+	name          string
+	identity      string
+	leaseDuration time.Duration
+	retryPeriod   time.Duration
+}
+
+//Lease type representing the json schema of a coordination.k8s.io/v1 Lease
+type Lease struct {
+	APIVersion string `json:"apiVersion"`
+	Kind       string `json:"kind"`
+	Metadata   struct {
+		Name            string `json:"name"`
+		Namespace       string `json:"namespace"`
+		ResourceVersion string `json:"resourceVersion,omitempty"`
+	} `json:"metadata"`
+	Spec struct {
+		HolderIdentity       string     `json:"holderIdentity"`
+		LeaseDurationSeconds int        `json:"leaseDurationSeconds"`
+		AcquireTime          *MicroTime `json:"acquireTime,omitempty"`
+		RenewTime            *MicroTime `json:"renewTime,omitempty"`
+		LeaseTransitions     int        `json:"leaseTransitions"`
+	} `json:"spec"`
+}
+
+//MicroTime type representing a k8s MicroTime, which is serialized with exactly microsecond precision
+type MicroTime struct {
+	time.Time
+}
+
+//microTimeFormat is the layout k8s serializes MicroTimes with
+const microTimeFormat = "2006-01-02T15:04:05.000000Z07:00"
+
+//followInterval is how often followers load the leader's latest scan from the alert store, see followLatestScan
+const followInterval = 30 * time.Second
+
+var (
+	//leading is 1 while this inspectr is the leader, and should scan and alert. Without leader election it's
+	// always 1
+	leading     int32 = 1
+	leaderGauge       = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "inspectr_leader",
+		Help: "Set to 1 if this inspectr is the leader, i.e. the one scanning and alerting.",
+	})
+)
+
+func init() {
+	prometheus.MustRegister(leaderGauge)
+	leaderGauge.Set(1)
+}
+
+//MicroTime implementation of json.Marshaler
+func (microTime MicroTime) MarshalJSON() ([]byte, error) {
+	return json.Marshal(microTime.UTC().Format(microTimeFormat))
+}
+
+//MicroTime implementation of json.Unmarshaler
+func (microTime *MicroTime) UnmarshalJSON(data []byte) (err error) {
+	var timeString string
+	err = json.Unmarshal(data, &timeString)
+	if err == nil {
+		microTime.Time, err = time.Parse(time.RFC3339Nano, timeString)
+	}
+	return
+}
+
+//isLeader returns a bool indicating whether this inspectr is the leader
+func isLeader() bool {
+	return atomic.LoadInt32(&leading) == 1
+}
+
+//setLeader records whether this inspectr is the leader
+func setLeader(leader bool) {
+	var value int32
+	if leader {
+		value = 1
+	}
+	if atomic.SwapInt32(&leading, value) != value {
+		glog.Infof("leader: %t", leader)
+	}
+	leaderGauge.Set(float64(value))
+}
+
+//leaderElectorFromString returns a LeaderElector for the Lease described by the specified [namespace]/[name] string,
+// or nil if the string is empty (leader election disabled)
+func leaderElectorFromString(leaseString, identity string) (elector *LeaderElector, err error) {
+	if leaseString != "" {
+		leaseSplit := strings.Split(leaseString, "/")
+		if len(leaseSplit) != 2 || leaseSplit[0] == "" || leaseSplit[1] == "" {
+			err = errors.New("invalid leader election lease " + leaseString + ", expected [namespace]/[name]")
+		} else {
+			var api *K8sAPI
+			api, err = inClusterK8sAPI()
+			if err == nil {
+				elector = &LeaderElector{api: api, namespace: leaseSplit[0], name: leaseSplit[1],
+					identity: identity, leaseDuration: 15 * time.Second, retryPeriod: 5 * time.Second}
+			}
+		}
+	}
+	return
+}
+
+//run starts trying to acquire (then renew) the Lease in the background. Leadership is given up if the Lease can't be
+// renewed before it would expire
+func (elector *LeaderElector) run() {
+	setLeader(false)
+	go func() {
+		var lastRenewed time.Time
+		for {
+			now := time.Now()
+			leader, err := elector.tryAcquireOrRenew(now)
+			if err != nil {
+				glog.Error(err)
+				leader = isLeader() && now.Sub(lastRenewed) < elector.leaseDuration
+			} else if leader {
+				lastRenewed = now
+			}
+			setLeader(leader)
+			time.Sleep(elector.retryPeriod)
+		}
+	}()
+}
+
+//leasePath returns the api path of the Lease, or of its collection if collection is true
+func (elector *LeaderElector) leasePath(collection bool) (path string) {
+	path = "/apis/coordination.k8s.io/v1/namespaces/" + elector.namespace + "/leases"
+	if !collection {
+		path += "/" + elector.name
+	}
+	return
+}
+
+//tryAcquireOrRenew returns a bool indicating whether this inspectr holds the Lease, after creating it, renewing it,
+// or taking it over if it's expired. Losing a race with another replica isn't an error, it just means we're not the
+// leader
+func (elector *LeaderElector) tryAcquireOrRenew(now time.Time) (leader bool, err error) {
+	var resp *http.Response
+	resp, err = elector.api.do("GET", elector.leasePath(false), nil)
+	if err == nil {
+		lease := new(Lease)
+		method := "PUT"
+		path := elector.leasePath(false)
+		switch resp.StatusCode {
+		case http.StatusOK:
+			err = json.NewDecoder(resp.Body).Decode(lease)
+		case http.StatusNotFound:
+			method = "POST"
+			path = elector.leasePath(true)
+			lease.Metadata.Name = elector.name
+			lease.Metadata.Namespace = elector.namespace
+		default:
+			err = k8sStatusError(resp, "GET", path)
+		}
+		resp.Body.Close()
+		if err == nil {
+			spec := &lease.Spec
+			held := spec.HolderIdentity != "" && spec.HolderIdentity != elector.identity
+			if held && spec.RenewTime != nil &&
+				now.Before(spec.RenewTime.Add(time.Duration(spec.LeaseDurationSeconds)*time.Second)) {
+				return
+			}
+			lease.APIVersion = "coordination.k8s.io/v1"
+			lease.Kind = "Lease"
+			if spec.HolderIdentity != elector.identity {
+				spec.HolderIdentity = elector.identity
+				spec.AcquireTime = &MicroTime{now}
+				if method == "PUT" {
+					spec.LeaseTransitions++
+				}
+			}
+			spec.LeaseDurationSeconds = int(elector.leaseDuration.Seconds())
+			spec.RenewTime = &MicroTime{now}
+			resp, err = elector.api.do(method, path, lease)
+			if err == nil {
+				switch resp.StatusCode {
+				case http.StatusOK, http.StatusCreated:
+					leader = true
+				case http.StatusConflict:
+				default:
+					err = k8sStatusError(resp, method, path)
+				}
+				resp.Body.Close()
+			}
+		}
+	}
+	return
+}
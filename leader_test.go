@@ -0,0 +1,120 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+//fakeLeases is a fake k8s api that stores a single Lease, enforcing resourceVersions
+type fakeLeases struct {
+	sync.Mutex
+	lease   *Lease
+	version int
+}
+
+func (fake *fakeLeases) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	fake.Lock()
+	defer fake.Unlock()
+	switch r.Method {
+	case "GET":
+		if fake.lease == nil {
+			http.NotFound(w, r)
+			return
+		}
+		json.NewEncoder(w).Encode(fake.lease)
+	case "POST", "PUT":
+		lease := new(Lease)
+		json.NewDecoder(r.Body).Decode(lease)
+		if (fake.lease == nil) != (r.Method == "POST") ||
+			(fake.lease != nil && fake.lease.Metadata.ResourceVersion != lease.Metadata.ResourceVersion) {
+			w.WriteHeader(http.StatusConflict)
+			return
+		}
+		fake.version++
+		lease.Metadata.ResourceVersion = strconv.Itoa(fake.version)
+		fake.lease = lease
+		json.NewEncoder(w).Encode(lease)
+	}
+}
+
+func TestTryAcquireOrRenew(t *testing.T) {
+	fake := &fakeLeases{}
+	server := httptest.NewServer(fake)
+	defer server.Close()
+	api := &K8sAPI{BaseURL: server.URL, Client: http.DefaultClient}
+	banana := &LeaderElector{api: api, namespace: "inspectr", name: "inspectr", identity: "banana",
+		leaseDuration: 15 * time.Second}
+	apples := &LeaderElector{api: api, namespace: "inspectr", name: "inspectr", identity: "apples",
+		leaseDuration: 15 * time.Second}
+	now := time.Date(2017, 6, 1, 10, 0, 0, 0, time.UTC)
+
+	var elections = []struct {
+		elector *LeaderElector
+		now     time.Time
+		leader  bool
+	}{
+		{banana, now, true},
+		{apples, now.Add(time.Second), false},
+		{banana, now.Add(5 * time.Second), true},
+		{apples, now.Add(19 * time.Second), false},
+		{apples, now.Add(21 * time.Second), true},
+		{banana, now.Add(22 * time.Second), false},
+	}
+	for i, election := range elections {
+		leader, err := election.elector.tryAcquireOrRenew(election.now)
+		if err != nil || leader != election.leader {
+			t.Errorf("election %d: tryAcquireOrRenew for %s returned %t, %v, expected %t", i,
+				election.elector.identity, leader, err, election.leader)
+		}
+	}
+	if fake.lease.Spec.HolderIdentity != "apples" || fake.lease.Spec.LeaseTransitions != 1 ||
+		!fake.lease.Spec.AcquireTime.Equal(now.Add(21*time.Second)) {
+		t.Errorf("lease ended up as %+v, expected apples to have taken it over once", fake.lease.Spec)
+	}
+}
+
+func TestMicroTime(t *testing.T) {
+	microTime := MicroTime{time.Date(2017, 6, 1, 10, 0, 0, 1500, time.UTC)}
+	data, err := json.Marshal(microTime)
+	if err != nil || string(data) != `"2017-06-01T10:00:00.000001Z"` {
+		t.Errorf("json.Marshal(MicroTime) returned %s, %v", data, err)
+	}
+	var decoded MicroTime
+	err = json.Unmarshal(data, &decoded)
+	if err != nil || !decoded.Equal(microTime.Truncate(time.Microsecond)) {
+		t.Errorf("json.Unmarshal(%s) returned %s, %v", data, decoded, err)
+	}
+}
+
+func TestFollowLatestScan(t *testing.T) {
+	defer setLatestScan(getLatestScan())
+	fake := &fakeK8sObjects{objects: make(map[string]map[string]interface{})}
+	server := httptest.NewServer(fake)
+	defer server.Close()
+	api := &K8sAPI{BaseURL: server.URL, Client: http.DefaultClient}
+	start := time.Date(2017, 6, 1, 10, 0, 0, 0, time.UTC)
+	leaderStore := &k8sStore{api: api, kind: "configmap", namespace: "inspectr", name: "inspectr-state"}
+	if err := leaderStore.SaveScan(newScan(start, start.Add(time.Second), map[string][]InspectrResult{
+		"project:cluster:eversc/app:app:app": {{Namespace: "default", Version: "v1", Upgrades: []string{"v2"}}},
+	}, nil)); err != nil {
+		t.Fatal(err)
+	}
+	setLatestScan(nil)
+	followLatestScan(&k8sStore{api: api, kind: "configmap", namespace: "inspectr", name: "inspectr-state"})
+	scan := getLatestScan()
+	if scan == nil || !scan.Start.Equal(start) || len(scan.Results) != 1 || scan.Results[0].Image != "eversc/app" {
+		t.Errorf("follower is serving scan %+v, expected the leader's", scan)
+	}
+	recorder := httptest.NewRecorder()
+	handleResults(recorder, httptest.NewRequest("GET", "/api/v1/results", nil))
+	if recorder.Code != http.StatusOK || !strings.Contains(recorder.Body.String(), `"image":"eversc/app"`) {
+		t.Errorf("follower's results api returned %d %s, expected the leader's results", recorder.Code,
+			recorder.Body.String())
+	}
+}
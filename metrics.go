@@ -0,0 +1,129 @@
+package main
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	upgradeNum = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "inspectr_upgrades_total",
+		Help: "Number of image upgrades currently available.",
+	})
+	resultLabels  = []string{"cluster", "namespace", "workload", "container", "image"}
+	upgradeResult = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "inspectr_upgrade_available",
+		Help: "Set to 1 for every running image version that has an upgrade available.",
+	}, append(resultLabels, "current_version", "latest_version", "upgrade_class"))
+	versionsBehind = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "inspectr_versions_behind",
+		Help: "Number of releases the running image tag is behind the newest available.",
+	}, resultLabels)
+	daysBehind = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "inspectr_days_behind",
+		Help: "Number of days the running image tag is older than the newest available.",
+	}, resultLabels)
+	scanDuration = prometheus.NewHistogram(prometheus.HistogramOpts{
+		Name:    "inspectr_scan_duration_seconds",
+		Help:    "Time taken to scan the cluster and registries for upgrades.",
+		Buckets: prometheus.ExponentialBuckets(0.5, 2, 10),
+	})
+	registryRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "inspectr_registry_requests_total",
+		Help: "Number of requests made to image registries, by host and status code.",
+	}, []string{"host", "code"})
+	lastSuccessfulScan = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "inspectr_last_successful_scan_timestamp_seconds",
+		Help: "Unix time of the last scan that completed without error.",
+	})
+	configGeneration = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "inspectr_config_generation",
+		Help: "Number of times the config has been loaded, incremented whenever a changed config file is applied.",
+	})
+	notifierFailures = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "inspectr_notifier_failures_total",
+		Help: "Number of times an output failed to send results or events, by output and receiver.",
+	}, []string{"output", "receiver"})
+)
+
+func init() {
+	// Metrics have to be registered to be exposed:
+	prometheus.MustRegister(upgradeNum)
+	prometheus.MustRegister(upgradeResult)
+	prometheus.MustRegister(versionsBehind)
+	prometheus.MustRegister(daysBehind)
+	prometheus.MustRegister(scanDuration)
+	prometheus.MustRegister(registryRequests)
+	prometheus.MustRegister(lastSuccessfulScan)
+	prometheus.MustRegister(configGeneration)
+	prometheus.MustRegister(notifierFailures)
+}
+
+//setResultMetrics replaces the per-image series with ones reflecting the specified results. Where more than one
+// result shares the same labels, the furthest behind is used for the lag gauges
+func setResultMetrics(upgradeMap map[string][]InspectrResult) {
+	upgradeNum.Set(float64(len(upgradeMap)))
+	upgradeResult.Reset()
+	versionsBehind.Reset()
+	daysBehind.Reset()
+	maxVersionsBehind := make(map[[5]string]int)
+	maxDaysBehind := make(map[[5]string]int)
+	for k, v := range upgradeMap {
+		for _, result := range v {
+			labels := resultLabelValues(k, result)
+			upgradeResult.WithLabelValues(append(labels[:], result.Version, result.LatestVersion,
+				result.UpgradeClass)...).Set(1)
+			if current, ok := maxVersionsBehind[labels]; !ok || result.VersionsBehind > current {
+				maxVersionsBehind[labels] = result.VersionsBehind
+			}
+			if current, ok := maxDaysBehind[labels]; result.DaysBehind >= 0 && (!ok || result.DaysBehind > current) {
+				maxDaysBehind[labels] = result.DaysBehind
+			}
+		}
+	}
+	for labels, value := range maxVersionsBehind {
+		versionsBehind.WithLabelValues(labels[:]...).Set(float64(value))
+	}
+	for labels, value := range maxDaysBehind {
+		daysBehind.WithLabelValues(labels[:]...).Set(float64(value))
+	}
+}
+
+//resultLabelValues returns the cluster, namespace, workload, container and image label values for a result
+func resultLabelValues(mapKey string, result InspectrResult) [5]string {
+	return [5]string{clusterFromInspectrMapKey(mapKey), result.Namespace, podFromInspectrMapKey(mapKey),
+		containerFromInspectrMapKey(mapKey), imageFromInspectrMapKey(mapKey)}
+}
+
+//setScanMetrics records how long the scan that started at scanStart took, and when it finished if it didn't error
+func setScanMetrics(scanStart time.Time, err error) {
+	scanDuration.Observe(time.Since(scanStart).Seconds())
+	if err == nil {
+		lastSuccessfulScan.SetToCurrentTime()
+	}
+}
+
+//registryGet fires off a GET request to an image registry, see registryDo
+func registryGet(client http.Client, uri string) (resp *http.Response, err error) {
+	var req *http.Request
+	req, err = http.NewRequest("GET", uri, nil)
+	if err == nil {
+		resp, err = registryDo(client, req)
+	}
+	return
+}
+
+//registryDo fires off the specified request to an image registry, counting it by host and status code ("error" if
+// no response was received)
+func registryDo(client http.Client, req *http.Request) (resp *http.Response, err error) {
+	resp, err = client.Do(req)
+	code := "error"
+	if err == nil {
+		code = strconv.Itoa(resp.StatusCode)
+	}
+	registryRequests.WithLabelValues(req.URL.Host, code).Inc()
+	return
+}
@@ -0,0 +1,269 @@
+package main
+
+import (
+	"time"
+
+	"github.com/golang/glog"
+)
+
+//Notifier type representing an output results are sent to, e.g. slack. Each output has its own schedule and alert
+// cache, and one output failing doesn't stop results being sent to the others
+type Notifier interface {
+	//FullReport sends every result with an upgrade available, which may be none
+	FullReport(upgradeMap map[string][]InspectrResult) error
+	//NewFindings sends results that haven't been sent to the output before
+	NewFindings(upgradeMap map[string][]InspectrResult) error
+	//Event sends a notice of something another output has done, e.g. created a JIRA issue
+	Event(event NotifierEvent) error
+}
+
+//Resolver type representing an output that acts on results it's sent before no longer applying, e.g. by closing their
+// JIRA issues
+type Resolver interface {
+	//Resolve is sent every result with an upgrade available after every scan. Images missing from it have been
+	// upgraded (or aren't running any more), unless their registry is in registryErrors, in which case it's unknown
+	Resolve(upgradeMap map[string][]InspectrResult, registryErrors map[string][]string) error
+}
+
+//NotifierEvent type representing something an output has done that's worth telling people about
+type NotifierEvent struct {
+	//Output is the name of the output the event happened in, e.g. "jira"
+	Output string `json:"output"`
+	//Kind is what happened, e.g. jiraCreatedEvent
+	Kind string `json:"kind"`
+	//MapKey is the inspectr map key of the results the event is about
+	MapKey string `json:"mapKey"`
+	//URL is where what happened can be seen, e.g. the JIRA issue
+	URL string `json:"url"`
+}
+
+//kinds of NotifierEvent
+const (
+	jiraCreatedEvent   = "jira-created"
+	jiraCommentedEvent = "jira-commented"
+	jiraClosedEvent    = "jira-closed"
+	jiraReopenedEvent  = "jira-reopened"
+)
+
+//notifierOutputs are the names of the outputs results can be sent to, in the order they're sent to
+var notifierOutputs = []string{"slack", "teams", "webhook", "email", "alertmanager", "jira", "github", "gitlab"}
+
+//everyScanOutputs are sent a full report after every scan, rather than on a schedule
+var everyScanOutputs = []string{"alertmanager"}
+
+//slackNotifier type: a Notifier that posts to a slack webhook
+type slackNotifier struct {
+	config SlackConfig
+}
+
+//jiraNotifier type: a Notifier that creates (or comments on) a JIRA issue for each image with upgrades, and a Resolver
+// that closes them once they're upgraded, if the config has a CloseTransition. The issues it's tracking to do so are
+// kept in alertState
+type jiraNotifier struct {
+	config     JiraConfig
+	receiver   string
+	alertState *AlertState
+	events     func(NotifierEvent)
+}
+
+//description returns a human readable description of the event
+func (event NotifierEvent) description() (description string) {
+	switch event.Kind {
+	case jiraCreatedEvent, githubCreatedEvent, gitlabCreatedEvent:
+		description = "just created " + event.URL
+	case jiraCommentedEvent, githubCommentedEvent, gitlabCommentedEvent:
+		description = "just commented on " + event.URL
+	case jiraClosedEvent:
+		description = "just closed " + event.URL
+	case jiraReopenedEvent:
+		description = "just reopened " + event.URL
+	default:
+		description = event.Kind + " " + event.URL
+	}
+	return
+}
+
+//slackNotifier implementation of Notifier
+func (notifier *slackNotifier) FullReport(upgradeMap map[string][]InspectrResult) error {
+	return outputResults(upgradeMap, notifier.config, true)
+}
+
+//slackNotifier implementation of Notifier
+func (notifier *slackNotifier) NewFindings(upgradeMap map[string][]InspectrResult) error {
+	return outputResults(upgradeMap, notifier.config, false)
+}
+
+//slackNotifier implementation of Notifier
+func (notifier *slackNotifier) Event(event NotifierEvent) error {
+	return postStringToSlack(event.description(), notifier.config)
+}
+
+//jiraNotifier implementation of Notifier
+func (notifier *jiraNotifier) FullReport(upgradeMap map[string][]InspectrResult) error {
+	return reportResults(upgradeMap, notifier.config, notifier.trackedIssues(), notifier.events)
+}
+
+//jiraNotifier implementation of Notifier
+func (notifier *jiraNotifier) NewFindings(upgradeMap map[string][]InspectrResult) error {
+	return reportResults(upgradeMap, notifier.config, notifier.trackedIssues(), notifier.events)
+}
+
+//jiraNotifier implementation of Notifier. Events aren't reported to JIRA
+func (notifier *jiraNotifier) Event(event NotifierEvent) error {
+	return nil
+}
+
+//jiraNotifier implementation of Resolver
+func (notifier *jiraNotifier) Resolve(upgradeMap map[string][]InspectrResult,
+	registryErrors map[string][]string) error {
+
+	return closeIssues(upgradeMap, registryErrors, notifier.config, notifier.trackedIssues(), notifier.events)
+}
+
+//trackedIssues returns the issues the receiver's jira output is tracking, keyed by inspectr map key, or nil if it
+// doesn't close issues, so doesn't need to track them
+func (notifier *jiraNotifier) trackedIssues() (trackedIssues map[string]*JiraIssue) {
+	if notifier.config.CloseTransition != "" {
+		trackedIssues = notifier.alertState.JiraIssues[notifier.receiver]
+		if trackedIssues == nil {
+			trackedIssues = make(map[string]*JiraIssue)
+			notifier.alertState.JiraIssues[notifier.receiver] = trackedIssues
+		}
+	}
+	return
+}
+
+//notifiers returns a Notifier for each of the receiver's enabled outputs, keyed by output name. Any state they keep
+// between scans (e.g. slack threads) is kept in alertState, and events is called with any events they raise
+func (receiver ReceiverConfig) notifiers(alertState *AlertState, events func(NotifierEvent)) (
+	notifiers map[string]Notifier) {
+
+	notifiers = make(map[string]Notifier)
+	if receiver.Slack != nil && receiver.Slack.Token != "" {
+		notifiers["slack"] = &slackAPINotifier{config: *receiver.Slack, receiver: receiver.Name,
+			alertState: alertState}
+	} else if receiver.Slack != nil {
+		notifiers["slack"] = &slackNotifier{config: *receiver.Slack}
+	}
+	if receiver.Teams != nil {
+		notifiers["teams"] = &teamsNotifier{config: *receiver.Teams}
+	}
+	if receiver.Webhook != nil {
+		notifiers["webhook"] = &webhookNotifier{config: *receiver.Webhook}
+	}
+	if receiver.Email != nil {
+		notifiers["email"] = &emailNotifier{config: *receiver.Email}
+	}
+	if receiver.Alertmanager != nil {
+		notifiers["alertmanager"] = &alertmanagerNotifier{config: *receiver.Alertmanager, receiver: receiver.Name,
+			alertState: alertState}
+	}
+	if receiver.Jira != nil {
+		notifiers["jira"] = &jiraNotifier{config: *receiver.Jira, receiver: receiver.Name, alertState: alertState,
+			events: events}
+	}
+	if receiver.GitHub != nil {
+		notifiers["github"] = &githubNotifier{config: *receiver.GitHub, events: events}
+	}
+	if receiver.GitLab != nil {
+		notifiers["gitlab"] = &gitlabNotifier{config: *receiver.GitLab, events: events}
+	}
+	return
+}
+
+//receiverNotifiers returns the Notifiers for each of the config's receivers, keyed by receiver name and then output
+// name. Events raised by a receiver's outputs are sent on by notifyEvent
+func receiverNotifiers(config *Config, alertState *AlertState) (notifiers map[string]map[string]Notifier) {
+	notifiers = make(map[string]map[string]Notifier)
+	for name, receiver := range config.receivers {
+		receiverName := name
+		notifiers[name] = receiver.notifiers(alertState, func(event NotifierEvent) {
+			notifyEvent(notifiers, receiverName, event)
+		})
+	}
+	return
+}
+
+//notifyReceivers sends each receiver its share of the results (see routeResults), through each of its outputs: every
+// result if the output's full report is due for the receiver (or forceFullReport, or it's one of everyScanOutputs),
+// otherwise any the output hasn't sent the receiver before. Each output keeps an alert cache and schedule per receiver
+// (see outputStateKey), so receivers fail independently: if an output fails for a receiver, the failure is logged and
+// counted, and the results it was sending are dropped from that receiver's alert cache, so they're sent again as new
+// findings after the next scan. Likewise a scheduled full report is only recorded as sent for the receivers that sent
+// it, so one that fails is retried after the next scan
+// Outputs that are Resolvers are then sent all of the receiver's results, whatever their schedule, along with the
+// scan's registryErrors
+func notifyReceivers(alertState *AlertState, config *Config, notifiers map[string]map[string]Notifier,
+	upgradeMap map[string][]InspectrResult, registryErrors map[string][]string, forceFullReport bool, now time.Time) {
+
+	receiverMaps := routeResults(config, upgradeMap)
+	for _, output := range notifierOutputs {
+		for name, receiverNotifiers := range notifiers {
+			if notifier, ok := receiverNotifiers[output]; ok {
+				stateKey := outputStateKey(output, name)
+				var occurrence time.Time
+				due := false
+				if !contains(everyScanOutputs, output) {
+					occurrence, due = alertState.fullReportDue(stateKey, config.schedules[output], now)
+				}
+				fullReport := contains(everyScanOutputs, output) || due || forceFullReport
+				receiverMap := alertState.registerAlerts(stateKey, receiverMaps[name], fullReport, now)
+				err := sendResults(notifier, receiverMap, fullReport)
+				if err != nil {
+					glog.Errorf("%s output failed for receiver %s: %v", output, name, err)
+					notifierFailures.WithLabelValues(output, name).Inc()
+					alertState.forgetAlerts(stateKey, receiverMap)
+				} else if due {
+					alertState.recordFullReport(stateKey, occurrence)
+				}
+			}
+		}
+	}
+	for _, output := range notifierOutputs {
+		for name, receiverNotifiers := range notifiers {
+			if resolver, ok := receiverNotifiers[output].(Resolver); ok {
+				if err := resolver.Resolve(receiverMaps[name], registryErrors); err != nil {
+					glog.Errorf("%s output failed to resolve results for receiver %s: %v", output, name, err)
+					notifierFailures.WithLabelValues(output, name).Inc()
+				}
+			}
+		}
+	}
+}
+
+//outputStateKey returns the key of the named output's state for the named receiver in AlertState.Outputs: just the
+// output's name for the default receiver (as it was before there were receivers), otherwise [output]/[receiver]
+func outputStateKey(output, receiver string) string {
+	if receiver == defaultReceiver {
+		return output
+	}
+	return output + "/" + receiver
+}
+
+//sendResults sends the results to the notifier as a full report, or as new findings if there are any
+func sendResults(notifier Notifier, upgradeMap map[string][]InspectrResult, fullReport bool) (err error) {
+	if fullReport {
+		err = notifier.FullReport(upgradeMap)
+	} else if len(upgradeMap) > 0 {
+		err = notifier.NewFindings(upgradeMap)
+	}
+	return
+}
+
+//notifyEvent sends an event raised by one of the receiver's outputs to the receiver's other outputs, or to the
+// default receiver's if it doesn't have any others
+func notifyEvent(notifiers map[string]map[string]Notifier, receiver string, event NotifierEvent) {
+	receiverNotifiers := notifiers[receiver]
+	if len(receiverNotifiers) < 2 {
+		receiverNotifiers = notifiers[defaultReceiver]
+	}
+	for output, notifier := range receiverNotifiers {
+		if output != event.Output {
+			if err := notifier.Event(event); err != nil {
+				glog.Errorf("%s output failed to send %s event for receiver %s: %v", output, event.Kind, receiver,
+					err)
+				notifierFailures.WithLabelValues(output, receiver).Inc()
+			}
+		}
+	}
+}
@@ -0,0 +1,143 @@
+package main
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+//fakeNotifier type: a Notifier that records what it's sent, failing with err if it's set
+type fakeNotifier struct {
+	fullReports []map[string][]InspectrResult
+	newFindings []map[string][]InspectrResult
+	events      []NotifierEvent
+	err         error
+}
+
+func (notifier *fakeNotifier) FullReport(upgradeMap map[string][]InspectrResult) error {
+	notifier.fullReports = append(notifier.fullReports, upgradeMap)
+	return notifier.err
+}
+
+func (notifier *fakeNotifier) NewFindings(upgradeMap map[string][]InspectrResult) error {
+	notifier.newFindings = append(notifier.newFindings, upgradeMap)
+	return notifier.err
+}
+
+func (notifier *fakeNotifier) Event(event NotifierEvent) error {
+	notifier.events = append(notifier.events, event)
+	return notifier.err
+}
+
+func TestNotifyReceivers(t *testing.T) {
+	config, err := parseConfig([]byte(routingConfig), env{}.lookup)
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Date(2017, 6, 1, 10, 0, 0, 0, time.UTC)
+	alertState := newAlertState()
+	defaultSlack := &fakeNotifier{}
+	paymentsSlack := &fakeNotifier{err: errors.New("webhook gone")}
+	notifiers := map[string]map[string]Notifier{
+		defaultReceiver: {"slack": defaultSlack},
+		"payments":      {"slack": paymentsSlack},
+	}
+	upgradeMap := map[string][]InspectrResult{
+		"project:cluster:eversc/app:app:app": {{Namespace: "default", Upgrades: []string{"v2"}},
+			{Namespace: "payments-prod", Upgrades: []string{"v2"}}},
+	}
+	for i := 0; i < 2; i++ {
+		notifyReceivers(alertState, config, notifiers, upgradeMap, nil, false, now)
+	}
+	if len(defaultSlack.newFindings) != 1 || len(defaultSlack.fullReports) != 0 {
+		t.Errorf("default receiver was sent %d new findings and %d full reports, expected 1 and 0",
+			len(defaultSlack.newFindings), len(defaultSlack.fullReports))
+	}
+	if len(paymentsSlack.newFindings) != 2 {
+		t.Errorf("failing payments receiver was sent %d new findings, expected them to be retried",
+			len(paymentsSlack.newFindings))
+	}
+	if _, ok := alertState.Outputs["jira"]; ok {
+		t.Error("notifyReceivers registered alerts for jira, which no receiver has")
+	}
+	notifyReceivers(alertState, config, notifiers, upgradeMap, nil, true, now)
+	if len(defaultSlack.fullReports) != 1 || len(paymentsSlack.fullReports) != 1 {
+		t.Errorf("forced full report sent %d and %d full reports, expected 1 each", len(defaultSlack.fullReports),
+			len(paymentsSlack.fullReports))
+	}
+}
+
+func TestNotifyReceiversFailIndependently(t *testing.T) {
+	config, err := parseConfig([]byte(routingConfig), env{}.lookup)
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Date(2017, 6, 1, 10, 0, 0, 0, time.UTC)
+	alertState := newAlertState()
+	securitySlack := &fakeNotifier{}
+	paymentsSlack := &fakeNotifier{err: errors.New("webhook gone")}
+	notifiers := map[string]map[string]Notifier{
+		"security": {"slack": securitySlack},
+		"payments": {"slack": paymentsSlack},
+	}
+	//routed to both security and payments
+	upgradeMap := map[string][]InspectrResult{
+		"project:cluster:eversc/openssl:app:app": {{Namespace: "payments-prod", Upgrades: []string{"v2"}}},
+	}
+	for i := 0; i < 3; i++ {
+		notifyReceivers(alertState, config, notifiers, upgradeMap, nil, false, now.Add(time.Duration(i)*time.Minute))
+	}
+	if len(securitySlack.newFindings) != 1 {
+		t.Errorf("security receiver was sent %d new findings, expected payments failing not to resend them",
+			len(securitySlack.newFindings))
+	}
+	if len(paymentsSlack.newFindings) != 3 {
+		t.Errorf("failing payments receiver was sent %d new findings, expected them to be retried every scan",
+			len(paymentsSlack.newFindings))
+	}
+}
+
+func TestNotifyReceiversRetriesFullReport(t *testing.T) {
+	config, err := parseConfig([]byte(routingConfig), env{}.lookup)
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Date(2017, 6, 1, 10, 0, 30, 0, time.UTC)
+	alertState := newAlertState()
+	alertState.recordFullReport("slack", now.Add(-time.Hour))
+	slack := &fakeNotifier{err: errors.New("slack is down")}
+	notifiers := map[string]map[string]Notifier{defaultReceiver: {"slack": slack}}
+	upgradeMap := map[string][]InspectrResult{
+		"project:cluster:eversc/app:app:app": {{Namespace: "default", Upgrades: []string{"v2"}}},
+	}
+	notifyReceivers(alertState, config, notifiers, upgradeMap, nil, false, now)
+	slack.err = nil
+	for i := 1; i < 3; i++ {
+		notifyReceivers(alertState, config, notifiers, upgradeMap, nil, false, now.Add(time.Duration(i)*time.Minute))
+	}
+	if len(slack.fullReports) != 2 || len(slack.newFindings) != 0 {
+		t.Errorf("slack was sent %d full reports and %d new findings, expected the failed full report to be retried "+
+			"once", len(slack.fullReports), len(slack.newFindings))
+	}
+}
+
+func TestNotifyEvent(t *testing.T) {
+	defaultSlack := &fakeNotifier{}
+	paymentsSlack := &fakeNotifier{}
+	notifiers := map[string]map[string]Notifier{
+		defaultReceiver: {"slack": defaultSlack, "jira": &fakeNotifier{}},
+		"payments":      {"slack": paymentsSlack, "jira": &fakeNotifier{}},
+		"security":      {"jira": &fakeNotifier{}},
+	}
+	event := NotifierEvent{"jira", jiraCreatedEvent, "project:cluster:eversc/app:app:app",
+		"https://jira.example.com/browse/PAY-1"}
+	notifyEvent(notifiers, "payments", event)
+	notifyEvent(notifiers, "security", event)
+	if len(paymentsSlack.events) != 1 || len(defaultSlack.events) != 1 {
+		t.Errorf("events sent to payments %v and default %v, expected one each", paymentsSlack.events,
+			defaultSlack.events)
+	}
+	if v := event.description(); v != "just created https://jira.example.com/browse/PAY-1" {
+		t.Errorf("description() returned %s", v)
+	}
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"strings"
+)
+
+//annotations teams can put on their namespaces and pods (e.g. via a deployment's pod template) to control inspectr.
+// A pod's annotation overrides its namespace's
+const (
+	excludeAnnotation       = "inspectr.io/exclude"
+	ownerAnnotation         = "inspectr.io/owner"
+	slackChannelAnnotation  = "inspectr.io/slack-channel"
+	jiraProjectAnnotation   = "inspectr.io/jira-project"
+	jiraComponentAnnotation = "inspectr.io/jira-component"
+)
+
+//Ownership type representing who owns a workload, and where its results should go
+type Ownership struct {
+	Team          string `json:"team,omitempty"`
+	SlackChannel  string `json:"slackChannel,omitempty"`
+	JiraProject   string `json:"jiraProject,omitempty"`
+	JiraComponent string `json:"jiraComponent,omitempty"`
+}
+
+//NamespaceMetadata type representing the metadata of a namespace
+type NamespaceMetadata struct {
+	Name        string            `json:"name"`
+	Labels      map[string]string `json:"labels"`
+	Annotations map[string]string `json:"annotations"`
+}
+
+//annotation returns the value of the specified annotation on the pod, or its namespace if the pod doesn't have it
+func annotation(key string, namespaceAnnotations, podAnnotations map[string]string) (value string) {
+	value, ok := podAnnotations[key]
+	if !ok {
+		value = namespaceAnnotations[key]
+	}
+	value = strings.TrimSpace(value)
+	return
+}
+
+//excluded returns a bool indicating whether the pod, or its namespace, has opted out of inspectr. A pod can opt back
+// in to an excluded namespace with "false"
+func excluded(namespaceAnnotations, podAnnotations map[string]string) (isExcluded bool) {
+	isExcluded = strings.EqualFold(annotation(excludeAnnotation, namespaceAnnotations, podAnnotations), "true")
+	return
+}
+
+//ownershipFromAnnotations returns the Ownership described by the pod's and its namespace's annotations
+func ownershipFromAnnotations(namespaceAnnotations, podAnnotations map[string]string) (ownership Ownership) {
+	ownership = Ownership{
+		Team:          annotation(ownerAnnotation, namespaceAnnotations, podAnnotations),
+		SlackChannel:  annotation(slackChannelAnnotation, namespaceAnnotations, podAnnotations),
+		JiraProject:   annotation(jiraProjectAnnotation, namespaceAnnotations, podAnnotations),
+		JiraComponent: annotation(jiraComponentAnnotation, namespaceAnnotations, podAnnotations),
+	}
+	return
+}
@@ -0,0 +1,37 @@
+package main
+
+import (
+	"testing"
+)
+
+var annotationsVars = []struct {
+	namespaceAnnotations map[string]string
+	podAnnotations       map[string]string
+	excluded             bool
+	ownership            Ownership
+}{
+	{nil, nil, false, Ownership{}},
+	{map[string]string{excludeAnnotation: "true"}, nil, true, Ownership{}},
+	{nil, map[string]string{excludeAnnotation: "True"}, true, Ownership{}},
+	{map[string]string{excludeAnnotation: "true"}, map[string]string{excludeAnnotation: "false"}, false, Ownership{}},
+	{nil, map[string]string{excludeAnnotation: "banana"}, false, Ownership{}},
+	{map[string]string{ownerAnnotation: "payments", slackChannelAnnotation: "#payments"},
+		map[string]string{ownerAnnotation: "checkout ", jiraProjectAnnotation: "PAY",
+			jiraComponentAnnotation: "checkout"},
+		false, Ownership{Team: "checkout", SlackChannel: "#payments", JiraProject: "PAY", JiraComponent: "checkout"}},
+}
+
+func TestAnnotations(t *testing.T) {
+	for _, annotationsVar := range annotationsVars {
+		if v := excluded(annotationsVar.namespaceAnnotations, annotationsVar.podAnnotations); v !=
+			annotationsVar.excluded {
+			t.Errorf("excluded(%v, %v) returned %t, expected %t", annotationsVar.namespaceAnnotations,
+				annotationsVar.podAnnotations, v, annotationsVar.excluded)
+		}
+		if v := ownershipFromAnnotations(annotationsVar.namespaceAnnotations, annotationsVar.podAnnotations); v !=
+			annotationsVar.ownership {
+			t.Errorf("ownershipFromAnnotations(%v, %v) returned %#v, expected %#v",
+				annotationsVar.namespaceAnnotations, annotationsVar.podAnnotations, v, annotationsVar.ownership)
+		}
+	}
+}
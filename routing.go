@@ -0,0 +1,429 @@
+package main
+
+import (
+	"path"
+	"strconv"
+	"strings"
+)
+
+//defaultReceiver is the receiver made up of the top level outputs, which results go to unless a route says otherwise
+const defaultReceiver = "default"
+
+//ReceiverConfig type representing a named set of destinations that routes can send results to. Anything a receiver
+// leaves out (e.g. JIRA credentials, the slack token of a receiver with just a channel, or HTTP client settings) is
+// taken from the top level outputs, and schedules are always those of the top level outputs
+type ReceiverConfig struct {
+	Name         string              `yaml:"name"`
+	Slack        *SlackConfig        `yaml:"slack"`
+	Teams        *TeamsConfig        `yaml:"teams"`
+	Webhook      *WebhookConfig      `yaml:"webhook"`
+	Email        *EmailConfig        `yaml:"email"`
+	Alertmanager *AlertmanagerConfig `yaml:"alertmanager"`
+	Jira         *JiraConfig         `yaml:"jira"`
+	GitHub       *GitHubConfig       `yaml:"github"`
+	GitLab       *GitLabConfig       `yaml:"gitlab"`
+}
+
+//RouteConfig type representing a node in the routing tree. A result goes to the receivers of the first child route
+// it matches (and of any matching siblings after that, while the matched routes have Continue set), or to this
+// route's receiver if it doesn't match any. A route with no receiver uses its parent's
+type RouteConfig struct {
+	Receiver string        `yaml:"receiver"`
+	Match    RouteMatch    `yaml:"match"`
+	Continue bool          `yaml:"continue"`
+	Routes   []RouteConfig `yaml:"routes"`
+}
+
+//RouteMatch type representing what a result has to match for a route to apply: every non-empty field has to match.
+// Namespaces and Images are globs, any of which can match, Labels are pod labels whose values are globs, all of which
+// have to match, and Owners are the teams named by the inspectr.io/owner annotation, any of which can match
+type RouteMatch struct {
+	Namespaces []string          `yaml:"namespaces"`
+	Images     []string          `yaml:"images"`
+	Labels     map[string]string `yaml:"labels"`
+	Owners     []string          `yaml:"owners"`
+}
+
+//matches returns a bool indicating whether the result (under the specified results map key) matches
+func (match *RouteMatch) matches(mapKey string, result InspectrResult) (matches bool) {
+	matches = (len(match.Namespaces) == 0 || matchesGlob(match.Namespaces, result.Namespace)) &&
+		(len(match.Images) == 0 || matchesGlob(match.Images, imageFromInspectrMapKey(mapKey))) &&
+		(len(match.Owners) == 0 || contains(match.Owners, result.Ownership.Team))
+	for key, pattern := range match.Labels {
+		value, ok := result.Labels[key]
+		if matched, _ := path.Match(pattern, value); !ok || !matched {
+			matches = false
+		}
+	}
+	return
+}
+
+//matchesGlob returns a bool indicating whether the value matches any of the specified globs
+func matchesGlob(globs []string, value string) (matches bool) {
+	for _, glob := range globs {
+		if matched, _ := path.Match(glob, value); matched {
+			matches = true
+			break
+		}
+	}
+	return
+}
+
+//receivers returns the names of the receivers the result (under the specified results map key) should go to,
+// walking the routing tree from this route. parentReceiver is the receiver to use if this route doesn't have one
+func (route *RouteConfig) receivers(mapKey string, result InspectrResult, parentReceiver string) (
+	receivers []string) {
+
+	receiver := route.Receiver
+	if receiver == "" {
+		receiver = parentReceiver
+	}
+	for i := range route.Routes {
+		child := &route.Routes[i]
+		if child.Match.matches(mapKey, result) {
+			for _, childReceiver := range child.receivers(mapKey, result, receiver) {
+				if !contains(receivers, childReceiver) {
+					receivers = append(receivers, childReceiver)
+				}
+			}
+			if !child.Continue {
+				break
+			}
+		}
+	}
+	if len(receivers) == 0 {
+		receivers = []string{receiver}
+	}
+	return
+}
+
+//routeResults returns the specified results split up by the receiver they should go to, according to the config's
+// routing tree. A result can go to more than one receiver
+func routeResults(config *Config, upgradeMap map[string][]InspectrResult) (
+	receiverMaps map[string]map[string][]InspectrResult) {
+
+	receiverMaps = make(map[string]map[string][]InspectrResult)
+	for k, v := range upgradeMap {
+		for _, result := range v {
+			for _, receiver := range config.Route.receivers(k, result, defaultReceiver) {
+				if receiverMaps[receiver] == nil {
+					receiverMaps[receiver] = make(map[string][]InspectrResult)
+				}
+				receiverMaps[receiver][k] = append(receiverMaps[receiver][k], result)
+			}
+		}
+	}
+	return
+}
+
+//resolveReceivers returns the config's receivers keyed by name, including the default receiver, with anything they
+// leave out taken from the top level outputs. Outputs that aren't enabled (e.g. JIRA without a URL, or disabled) are
+// nil
+func resolveReceivers(config *Config) (receivers map[string]ReceiverConfig) {
+	receivers = make(map[string]ReceiverConfig)
+	receivers[defaultReceiver] = ReceiverConfig{Name: defaultReceiver, Slack: &config.Outputs.Slack,
+		Teams: &config.Outputs.Teams, Webhook: &config.Outputs.Webhook, Email: &config.Outputs.Email,
+		Alertmanager: &config.Outputs.Alertmanager, Jira: &config.Outputs.Jira, GitHub: &config.Outputs.GitHub,
+		GitLab: &config.Outputs.GitLab}
+	for _, receiver := range config.Receivers {
+		if receiver.Slack != nil && receiver.Slack.WebhookID == "" && receiver.Slack.Token == "" {
+			slackConfig := *receiver.Slack
+			slackConfig.Token = config.Outputs.Slack.Token
+			if slackConfig.APIURL == "" {
+				slackConfig.APIURL = config.Outputs.Slack.APIURL
+			}
+			receiver.Slack = &slackConfig
+		}
+		if receiver.Slack != nil && receiver.Slack.HTTP.isZero() {
+			slackConfig := *receiver.Slack
+			slackConfig.HTTP = config.Outputs.Slack.HTTP
+			receiver.Slack = &slackConfig
+		}
+		if receiver.Teams != nil && receiver.Teams.HTTP.isZero() {
+			teamsConfig := *receiver.Teams
+			teamsConfig.HTTP = config.Outputs.Teams.HTTP
+			receiver.Teams = &teamsConfig
+		}
+		if receiver.Webhook != nil && receiver.Webhook.HTTP.isZero() {
+			webhookConfig := *receiver.Webhook
+			webhookConfig.HTTP = config.Outputs.Webhook.HTTP
+			receiver.Webhook = &webhookConfig
+		}
+		if receiver.Email != nil {
+			emailConfig := *receiver.Email
+			defaults := config.Outputs.Email
+			for _, field := range []struct {
+				value        *string
+				defaultValue string
+			}{
+				{&emailConfig.Host, defaults.Host},
+				{&emailConfig.StartTLS, defaults.StartTLS},
+				{&emailConfig.Username, defaults.Username},
+				{&emailConfig.Password, defaults.Password},
+				{&emailConfig.From, defaults.From},
+			} {
+				if *field.value == "" {
+					*field.value = field.defaultValue
+				}
+			}
+			if emailConfig.Port == 0 {
+				emailConfig.Port = defaults.Port
+			}
+			receiver.Email = &emailConfig
+		}
+		if receiver.Alertmanager != nil {
+			alertmanagerConfig := *receiver.Alertmanager
+			defaults := config.Outputs.Alertmanager
+			if alertmanagerConfig.URL == "" {
+				alertmanagerConfig.URL = defaults.URL
+			}
+			if alertmanagerConfig.ResolveTimeout == "" {
+				alertmanagerConfig.ResolveTimeout = defaults.ResolveTimeout
+			}
+			if alertmanagerConfig.HTTP.isZero() {
+				alertmanagerConfig.HTTP = defaults.HTTP
+			}
+			labels := make(map[string]string)
+			for _, labelMap := range []map[string]string{defaults.Labels, alertmanagerConfig.Labels} {
+				for k, v := range labelMap {
+					labels[k] = v
+				}
+			}
+			alertmanagerConfig.Labels = labels
+			receiver.Alertmanager = &alertmanagerConfig
+		}
+		if receiver.Jira != nil {
+			jiraConfig := *receiver.Jira
+			defaults := config.Outputs.Jira
+			for _, field := range []struct {
+				value        *string
+				defaultValue string
+			}{
+				{&jiraConfig.URL, defaults.URL},
+				{&jiraConfig.User, defaults.User},
+				{&jiraConfig.Password, defaults.Password},
+				{&jiraConfig.Project, defaults.Project},
+				{&jiraConfig.IssueType, defaults.IssueType},
+			} {
+				if *field.value == "" {
+					*field.value = field.defaultValue
+				}
+			}
+			if jiraConfig.URL != "" && !strings.HasSuffix(jiraConfig.URL, "/") {
+				jiraConfig.URL += "/"
+			}
+			if jiraConfig.Fields == nil {
+				jiraConfig.Fields = defaults.Fields
+			}
+			if jiraConfig.CloseTransition == "" {
+				jiraConfig.CloseTransition = defaults.CloseTransition
+				if jiraConfig.ReopenTransition == "" {
+					jiraConfig.ReopenTransition = defaults.ReopenTransition
+				}
+			}
+			receiver.Jira = &jiraConfig
+		}
+		if receiver.GitHub != nil {
+			githubConfig := *receiver.GitHub
+			defaults := config.Outputs.GitHub
+			if githubConfig.URL == "" {
+				githubConfig.URL = defaults.URL
+			}
+			if githubConfig.Token == "" {
+				githubConfig.Token = defaults.Token
+			}
+			if githubConfig.Labels == nil {
+				githubConfig.Labels = defaults.Labels
+			}
+			if githubConfig.HTTP.isZero() {
+				githubConfig.HTTP = defaults.HTTP
+			}
+			receiver.GitHub = &githubConfig
+		}
+		if receiver.GitLab != nil {
+			gitlabConfig := *receiver.GitLab
+			defaults := config.Outputs.GitLab
+			if gitlabConfig.URL == "" {
+				gitlabConfig.URL = defaults.URL
+			}
+			if gitlabConfig.Token == "" {
+				gitlabConfig.Token = defaults.Token
+			}
+			if gitlabConfig.Labels == nil {
+				gitlabConfig.Labels = defaults.Labels
+			}
+			if gitlabConfig.HTTP.isZero() {
+				gitlabConfig.HTTP = defaults.HTTP
+			}
+			receiver.GitLab = &gitlabConfig
+		}
+		receivers[receiver.Name] = receiver
+	}
+	for name, receiver := range receivers {
+		if receiver.Slack != nil && ((receiver.Slack.WebhookID == "" && receiver.Slack.Token == "") ||
+			receiver.Slack.Disabled) {
+			receiver.Slack = nil
+		}
+		if receiver.Teams != nil && (receiver.Teams.WebhookURL == "" || receiver.Teams.Disabled) {
+			receiver.Teams = nil
+		}
+		if receiver.Webhook != nil && (receiver.Webhook.URL == "" || receiver.Webhook.Disabled) {
+			receiver.Webhook = nil
+		}
+		if receiver.Email != nil && (receiver.Email.Host == "" || len(receiver.Email.To) == 0 ||
+			receiver.Email.Disabled) {
+			receiver.Email = nil
+		}
+		if receiver.Alertmanager != nil && (receiver.Alertmanager.URL == "" || receiver.Alertmanager.Disabled) {
+			receiver.Alertmanager = nil
+		}
+		if receiver.Jira != nil && (receiver.Jira.URL == "" || receiver.Jira.Disabled) {
+			receiver.Jira = nil
+		}
+		if receiver.GitHub != nil && (receiver.GitHub.Repo == "" || receiver.GitHub.Disabled) {
+			receiver.GitHub = nil
+		}
+		if receiver.GitLab != nil && (receiver.GitLab.Project == "" || receiver.GitLab.Disabled) {
+			receiver.GitLab = nil
+		}
+		receivers[name] = receiver
+	}
+	return
+}
+
+//validateRoutes adds a problem for each receiver or route in the config that's invalid
+func validateRoutes(config *Config, problem func(path, message string)) {
+	names := map[string]struct{}{defaultReceiver: {}}
+	for i, receiver := range config.Receivers {
+		receiverPath := "receivers[" + strconv.Itoa(i) + "]"
+		if receiver.Name == "" {
+			problem(receiverPath+".name", "required")
+		} else if _, ok := names[receiver.Name]; ok {
+			problem(receiverPath+".name", "duplicate receiver \""+receiver.Name+"\" (\""+defaultReceiver+
+				"\" is the top level outputs)")
+		}
+		names[receiver.Name] = struct{}{}
+		if receiver.Slack != nil {
+			if receiver.Slack.WebhookID == "" && receiver.Slack.Token == "" && config.Outputs.Slack.Token == "" {
+				problem(receiverPath+".slack.webhookID", "required, or a token (which can be set in outputs.slack)")
+			}
+			validateSlack(receiver.Slack, receiverPath+".slack", problem)
+		}
+		if receiver.Slack != nil && receiver.Slack.Schedule != "" {
+			problem(receiverPath+".slack.schedule", "receivers use outputs.slack.schedule")
+		}
+		if receiver.Teams != nil {
+			if receiver.Teams.WebhookURL == "" {
+				problem(receiverPath+".teams.webhookURL", "required")
+			}
+			if receiver.Teams.Schedule != "" {
+				problem(receiverPath+".teams.schedule", "receivers use outputs.teams.schedule")
+			}
+			validateTeams(receiver.Teams, receiverPath+".teams", problem)
+		}
+		if receiver.Webhook != nil {
+			if receiver.Webhook.URL == "" {
+				problem(receiverPath+".webhook.url", "required")
+			}
+			if receiver.Webhook.Schedule != "" {
+				problem(receiverPath+".webhook.schedule", "receivers use outputs.webhook.schedule")
+			}
+			validateWebhook(receiver.Webhook, receiverPath+".webhook", problem)
+		}
+		if receiver.Email != nil {
+			if len(receiver.Email.To) == 0 {
+				problem(receiverPath+".email.to", "required")
+			}
+			if receiver.Email.Host == "" && config.Outputs.Email.Host == "" {
+				problem(receiverPath+".email.host", "required, or set it in outputs.email")
+			}
+			if receiver.Email.From == "" && config.Outputs.Email.From == "" {
+				problem(receiverPath+".email.from", "required, or set it in outputs.email")
+			}
+			if receiver.Email.Schedule != "" {
+				problem(receiverPath+".email.schedule", "receivers use outputs.email.schedule")
+			}
+			validateEmail(receiver.Email, receiverPath+".email", problem)
+		}
+		if receiver.Alertmanager != nil {
+			if receiver.Alertmanager.URL == "" && config.Outputs.Alertmanager.URL == "" {
+				problem(receiverPath+".alertmanager.url", "required, or set it in outputs.alertmanager")
+			}
+			validateAlertmanager(receiver.Alertmanager, receiverPath+".alertmanager", problem)
+		}
+		if receiver.GitHub != nil {
+			if receiver.GitHub.Repo == "" {
+				problem(receiverPath+".github.repo", "required")
+			}
+			if receiver.GitHub.Token == "" && config.Outputs.GitHub.Token == "" {
+				problem(receiverPath+".github.token", "required, or set it in outputs.github")
+			}
+			if receiver.GitHub.Schedule != "" {
+				problem(receiverPath+".github.schedule", "receivers use outputs.github.schedule")
+			}
+			validateGitHub(receiver.GitHub, receiverPath+".github", problem)
+		}
+		if receiver.GitLab != nil {
+			if receiver.GitLab.Project == "" {
+				problem(receiverPath+".gitlab.project", "required")
+			}
+			if receiver.GitLab.Token == "" && config.Outputs.GitLab.Token == "" {
+				problem(receiverPath+".gitlab.token", "required, or set it in outputs.gitlab")
+			}
+			if receiver.GitLab.Schedule != "" {
+				problem(receiverPath+".gitlab.schedule", "receivers use outputs.gitlab.schedule")
+			}
+			validateGitLab(receiver.GitLab, receiverPath+".gitlab", problem)
+		}
+		if receiver.Jira != nil && receiver.Jira.URL == "" && config.Outputs.Jira.URL == "" {
+			problem(receiverPath+".jira.url", "required, or set it in outputs.jira")
+		}
+		if receiver.Jira != nil && receiver.Jira.Schedule != "" {
+			problem(receiverPath+".jira.schedule", "receivers use outputs.jira.schedule")
+		}
+	}
+	for name, receiver := range resolveReceivers(config) {
+		if receiver.Jira != nil && name != defaultReceiver {
+			for _, field := range []struct {
+				name  string
+				value string
+			}{
+				{"user", receiver.Jira.User},
+				{"password", receiver.Jira.Password},
+				{"project", receiver.Jira.Project},
+				{"issueType", receiver.Jira.IssueType},
+			} {
+				if field.value == "" {
+					problem("receivers."+name+".jira."+field.name, "required, or set it in outputs.jira")
+				}
+			}
+			validateJira(receiver.Jira, "receivers."+name+".jira", problem)
+		}
+	}
+	validateRoute(&config.Route, "route", names, problem)
+}
+
+//validateRoute adds a problem for anything in the specified route, or its children, that's invalid
+func validateRoute(route *RouteConfig, routePath string, names map[string]struct{},
+	problem func(path, message string)) {
+
+	if _, ok := names[route.Receiver]; route.Receiver != "" && !ok {
+		problem(routePath+".receiver", "unknown receiver \""+route.Receiver+"\"")
+	}
+	globs := map[string][]string{"namespaces": route.Match.Namespaces, "images": route.Match.Images}
+	for field, patterns := range globs {
+		for i, pattern := range patterns {
+			if _, err := path.Match(pattern, ""); err != nil {
+				problem(routePath+".match."+field+"["+strconv.Itoa(i)+"]", "invalid glob \""+pattern+"\"")
+			}
+		}
+	}
+	for key, pattern := range route.Match.Labels {
+		if _, err := path.Match(pattern, ""); err != nil {
+			problem(routePath+".match.labels."+key, "invalid glob \""+pattern+"\"")
+		}
+	}
+	for i := range route.Routes {
+		validateRoute(&route.Routes[i], routePath+".routes["+strconv.Itoa(i)+"]", names, problem)
+	}
+}
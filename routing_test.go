@@ -0,0 +1,175 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+var routingConfig = `
+outputs:
+  slack:
+    webhookID: T00/B00/DEFAULT
+  jira:
+    url: https://jira.example.com/
+    user: inspectr
+    password: s3cret
+    project: OPS
+    issueType: Task
+receivers:
+  - name: payments
+    slack:
+      webhookID: T00/B00/PAYMENTS
+    jira:
+      project: PAY
+  - name: web
+    slack:
+      webhookID: T00/B00/WEB
+  - name: security
+    jira:
+      issueType: Bug
+route:
+  routes:
+    - receiver: security
+      match:
+        images: ["*/openssl*"]
+      continue: true
+    - receiver: payments
+      match:
+        namespaces: ["payments-*"]
+      routes:
+        - receiver: web
+          match:
+            labels:
+              tier: web*
+    - receiver: web
+      match:
+        owners: [frontend]
+`
+
+var routedResults = []struct {
+	mapKey    string
+	result    InspectrResult
+	receivers []string
+}{
+	{"project:cluster:eversc/app:app:app", InspectrResult{Namespace: "default"}, []string{"default"}},
+	{"project:cluster:eversc/app:app:app", InspectrResult{Namespace: "payments-prod"}, []string{"payments"}},
+	{"project:cluster:eversc/app:app:app", InspectrResult{Namespace: "payments-prod",
+		Labels: map[string]string{"tier": "web-frontend"}}, []string{"web"}},
+	{"project:cluster:eversc/app:app:app", InspectrResult{Namespace: "payments-prod",
+		Labels: map[string]string{"tier": "db"}}, []string{"payments"}},
+	{"project:cluster:eversc/app:app:app", InspectrResult{Namespace: "default",
+		Ownership: Ownership{Team: "frontend"}}, []string{"web"}},
+	{"project:cluster:eversc/openssl:app:app", InspectrResult{Namespace: "default"}, []string{"security"}},
+	{"project:cluster:eversc/openssl:app:app", InspectrResult{Namespace: "payments-prod"},
+		[]string{"security", "payments"}},
+}
+
+func TestRouteReceivers(t *testing.T) {
+	config, err := parseConfig([]byte(routingConfig), env{}.lookup)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, routedResult := range routedResults {
+		v := config.Route.receivers(routedResult.mapKey, routedResult.result, defaultReceiver)
+		if !reflect.DeepEqual(v, routedResult.receivers) {
+			t.Errorf("receivers(%s, %#v) returned %v, expected %v", routedResult.mapKey, routedResult.result, v,
+				routedResult.receivers)
+		}
+	}
+	upgradeMap := map[string][]InspectrResult{
+		"project:cluster:eversc/openssl:app:app": {{Namespace: "default"}, {Namespace: "payments-prod"}},
+	}
+	receiverMaps := routeResults(config, upgradeMap)
+	key := "project:cluster:eversc/openssl:app:app"
+	if len(receiverMaps) != 2 || len(receiverMaps["security"][key]) != 2 || len(receiverMaps["payments"][key]) != 1 {
+		t.Errorf("routeResults returned %#v, expected both results for security and one for payments", receiverMaps)
+	}
+}
+
+func TestResolveReceivers(t *testing.T) {
+	config, err := parseConfig([]byte(routingConfig), env{}.lookup)
+	if err != nil {
+		t.Fatal(err)
+	}
+	payments := config.receivers["payments"]
+	if payments.Slack.WebhookID != "T00/B00/PAYMENTS" || payments.Jira.Project != "PAY" ||
+		payments.Jira.User != "inspectr" || payments.Jira.IssueType != "Task" {
+		t.Errorf("payments receiver resolved to %#v, %#v", payments.Slack, payments.Jira)
+	}
+	if web := config.receivers["web"]; web.Jira != nil {
+		t.Errorf("web receiver resolved to JIRA %#v, expected none", web.Jira)
+	}
+	if security := config.receivers["security"]; security.Slack != nil || security.Jira.IssueType != "Bug" ||
+		security.Jira.Project != "OPS" {
+		t.Errorf("security receiver resolved to %#v, %#v", security.Slack, security.Jira)
+	}
+	config, err = parseConfig([]byte("outputs:\n  slack: {token: xoxb-test, channel: \"#upgrades\"}\n"+
+		"receivers:\n  - name: web\n    slack: {channel: \"#web\"}"), env{}.lookup)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if web := config.receivers["web"]; web.Slack.Token != "xoxb-test" || web.Slack.Channel != "#web" {
+		t.Errorf("web receiver resolved to slack %#v, expected the outputs.slack token", web.Slack)
+	}
+	config, err = parseConfig([]byte(`
+outputs:
+  jira: {url: https://jira.example.com, user: inspectr, password: s3cret, project: OPS, issueType: Task}
+receivers:
+  - name: platform
+    jira: {url: https://platform.atlassian.net}
+`), env{}.lookup)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v := config.receivers["platform"].Jira.URL; v != "https://platform.atlassian.net/" {
+		t.Errorf("platform receiver resolved to JIRA url %s, expected it to end in /", v)
+	}
+}
+
+var invalidRoutingConfigs = []struct {
+	yaml  string
+	error string
+}{
+	{"receivers:\n  - name: default", "receivers[0].name: duplicate receiver \"default\""},
+	{"receivers:\n  - slack: {webhookID: abc}", "receivers[0].name: required"},
+	{"receivers:\n  - name: a\n    slack: {}", "receivers[0].slack.webhookID: required"},
+	{"receivers:\n  - name: a\n    slack: {token: xoxb-test}", "receivers[0].slack.channel: required when token"},
+	{"receivers:\n  - name: a\n    teams: {}", "receivers[0].teams.webhookURL: required"},
+	{"receivers:\n  - name: a\n    teams: {webhookURL: https://teams.example.com, schedule: \"0 9 * * *\"}",
+		"receivers[0].teams.schedule: receivers use outputs.teams.schedule"},
+	{"receivers:\n  - name: a\n    jira: {project: PAY}", "receivers[0].jira.url: required, or set it in outputs.jira"},
+	{"receivers:\n  - name: a\n    jira: {url: https://jira.example.com/}", "receivers.a.jira.project: required"},
+	{"receivers:\n  - name: a\n    jira: {url: https://jira.example.com/, reopenTransition: Reopen}",
+		"receivers.a.jira.reopenTransition: requires closeTransition"},
+	{"route:\n  routes:\n    - receiver: banana", "route.routes[0].receiver: unknown receiver \"banana\""},
+	{"route:\n  routes:\n    - match: {namespaces: [\"[\"]}", "route.routes[0].match.namespaces[0]: invalid glob"},
+}
+
+func TestValidateRoutes(t *testing.T) {
+	for _, invalidRoutingConfig := range invalidRoutingConfigs {
+		if _, err := parseConfig([]byte(invalidRoutingConfig.yaml), env{}.lookup); err == nil ||
+			!strings.Contains(err.Error(), invalidRoutingConfig.error) {
+			t.Errorf("parseConfig(%s) returned error %v, expected it to contain %s", invalidRoutingConfig.yaml, err,
+				invalidRoutingConfig.error)
+		}
+	}
+}
+
+func TestSlackChannelMaps(t *testing.T) {
+	upgradeMap := map[string][]InspectrResult{
+		"banana-key": {{Namespace: "a"}, {Namespace: "b", Ownership: Ownership{SlackChannel: "#b"}}},
+		"apples-key": {{Namespace: "b", Ownership: Ownership{SlackChannel: "#b"}}},
+	}
+	channelMaps := slackChannelMaps(upgradeMap)
+	if len(channelMaps) != 2 || len(channelMaps[""]) != 1 || len(channelMaps["#b"]) != 2 {
+		t.Errorf("slackChannelMaps returned %#v", channelMaps)
+	}
+	if v := jiraProject(JiraConfig{Project: "OPS"}, upgradeMap["banana-key"]); v != "OPS" {
+		t.Errorf("jiraProject returned %s, expected OPS", v)
+	}
+	if v := jiraProject(JiraConfig{Project: "OPS"}, []InspectrResult{{Ownership: Ownership{JiraProject: "PAY"}}}); v !=
+		"PAY" {
+		t.Errorf("jiraProject returned %s, expected PAY", v)
+	}
+}
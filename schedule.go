@@ -0,0 +1,147 @@
+package main
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/robfig/cron/v3"
+)
+
+//Schedule type representing when the full set of results should be output: one or more cron expressions
+type Schedule struct {
+	Spec      string
+	schedules []cron.Schedule
+}
+
+//defaultSchedule is 10:00 daily
+const defaultSchedule = "0 10 * * *"
+
+//scanInterval is the longest inspectr sleeps between scans
+const scanInterval = 60 * time.Second
+
+//parseSchedule returns the Schedule described by the specified string, which is a ";" separated list of any of:
+//
+//     a standard 5 field cron expression, e.g. "30 14 * * MON,THU"
+//     hhmm, e.g. "1430" (daily)
+//     weekday|hhmm, e.g. "tuesday|1430" (weekly, weekday isn't case-sensitive)
+//
+// An empty string is the defaultSchedule. Any invalid entry is an error
+func parseSchedule(scheduleString string) (schedule *Schedule, err error) {
+	if strings.TrimSpace(scheduleString) == "" {
+		scheduleString = defaultSchedule
+	}
+	schedule = &Schedule{Spec: scheduleString}
+	for _, entry := range strings.Split(scheduleString, ";") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		var cronSchedule cron.Schedule
+		cronSchedule, err = cron.ParseStandard(cronFromLegacySchedule(entry))
+		if err != nil {
+			err = errors.New("invalid schedule \"" + entry + "\": " + err.Error())
+			schedule = nil
+			return
+		}
+		schedule.schedules = append(schedule.schedules, cronSchedule)
+	}
+	if len(schedule.schedules) == 0 {
+		err = errors.New("invalid schedule \"" + scheduleString + "\": no schedules found")
+		schedule = nil
+	}
+	return
+}
+
+//cronFromLegacySchedule returns the cron expression equivalent to a hhmm or weekday|hhmm schedule. Anything else is
+// returned as is
+func cronFromLegacySchedule(entry string) (cronExpression string) {
+	cronExpression = entry
+	scheduleSplit := strings.Split(entry, "|")
+	dayOfWeek := "*"
+	if len(scheduleSplit) == 2 && isValidDayOfWeek(strings.ToUpper(scheduleSplit[0])) {
+		dayOfWeek = strings.ToUpper(scheduleSplit[0])[0:3]
+		scheduleSplit = scheduleSplit[1:]
+	}
+	if len(scheduleSplit) == 1 && len(scheduleSplit[0]) == 4 {
+		hourInt, err := strconv.Atoi(scheduleSplit[0][0:2])
+		if err == nil {
+			var minInt int
+			minInt, err = strconv.Atoi(scheduleSplit[0][2:4])
+			if err == nil && hourInt >= 0 && hourInt < 24 && minInt >= 0 && minInt < 60 {
+				cronExpression = strconv.Itoa(minInt) + " " + strconv.Itoa(hourInt) + " * * " + dayOfWeek
+			}
+		}
+	}
+	return
+}
+
+//next returns the earliest scheduled time after the specified time. The schedule is evaluated in after's location
+func (schedule *Schedule) next(after time.Time) (next time.Time) {
+	for _, cronSchedule := range schedule.schedules {
+		scheduled := cronSchedule.Next(after)
+		if next.IsZero() || scheduled.Before(next) {
+			next = scheduled
+		}
+	}
+	return
+}
+
+//dueOccurrence returns the latest scheduled time after lastFired that isn't after now, and a bool indicating whether
+// there is one. Any earlier occurrences since lastFired are skipped, so after downtime only one report is caught up on.
+// The schedule is evaluated in now's location
+func (schedule *Schedule) dueOccurrence(lastFired, now time.Time) (occurrence time.Time, due bool) {
+	scheduled := schedule.next(lastFired.In(now.Location()))
+	for !scheduled.IsZero() && !scheduled.After(now) {
+		occurrence = scheduled
+		due = true
+		scheduled = schedule.next(scheduled)
+	}
+	return
+}
+
+//nextScheduled returns the earliest time any of the schedules is next due after now
+func nextScheduled(schedules map[string]*Schedule, now time.Time) (next time.Time) {
+	for _, schedule := range schedules {
+		scheduled := schedule.next(now)
+		if next.IsZero() || scheduled.Before(next) {
+			next = scheduled
+		}
+	}
+	return
+}
+
+//sleepTime returns an int of the number of seconds to go to sleep for. Sleep is needed so the process isn't
+// constantly running. It's never longer than scanInterval, and wakes up (at least a second) after the next scheduled
+// full report is due
+func sleepTime(now, nextScheduled time.Time) (sleepTime int) {
+	sleep := scanInterval
+	if !nextScheduled.IsZero() && nextScheduled.Sub(now) < sleep {
+		sleep = nextScheduled.Sub(now).Truncate(time.Second) + time.Second
+	}
+	sleepTime = int(sleep.Seconds())
+	if sleepTime < 1 {
+		sleepTime = 1
+	}
+	return
+}
+
+//outputSchedules returns the Schedule for each output: the output's own schedule string if it has one, otherwise
+// the default schedule string
+func outputSchedules(defaultScheduleString string, outputScheduleStrings map[string]string) (
+	schedules map[string]*Schedule, err error) {
+
+	schedules = make(map[string]*Schedule)
+	for output, scheduleString := range outputScheduleStrings {
+		if scheduleString == "" {
+			scheduleString = defaultScheduleString
+		}
+		schedules[output], err = parseSchedule(scheduleString)
+		if err != nil {
+			err = errors.New(output + ": " + err.Error())
+			break
+		}
+	}
+	return
+}
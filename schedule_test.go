@@ -0,0 +1,140 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+var scheduleStrings = []struct {
+	scheduleString string
+	valid          bool
+}{
+	{"", true},
+	{"1430", true},
+	{"tuesday|1430", true},
+	{"TUESDAY|1430", true},
+	{"30 14 * * MON,THU", true},
+	{"0 10 * * MON; 0 10 * * THU", true},
+	{"@daily", true},
+	{"4830", false},
+	{"1234567", false},
+	{"banana|1430", false},
+	{"tuesday|", false},
+	{"apples", false},
+	{"0 10 * *", false},
+	{";", false},
+}
+
+func TestParseSchedule(t *testing.T) {
+	for _, scheduleString := range scheduleStrings {
+		if _, err := parseSchedule(scheduleString.scheduleString); (err == nil) != scheduleString.valid {
+			t.Errorf("parseSchedule(%s) returned error %v, expected valid: %t", scheduleString.scheduleString,
+				err, scheduleString.valid)
+		}
+	}
+}
+
+var dueOccurrences = []struct {
+	scheduleString string
+	lastFired      time.Time
+	now            time.Time
+	occurrence     time.Time
+	due            bool
+}{
+	//2017-06-01 is a thursday
+	{"", time.Date(2017, 5, 31, 10, 0, 0, 0, time.UTC), time.Date(2017, 6, 1, 10, 0, 0, 0, time.UTC),
+		time.Date(2017, 6, 1, 10, 0, 0, 0, time.UTC), true},
+	{"", time.Date(2017, 5, 31, 10, 0, 0, 0, time.UTC), time.Date(2017, 6, 1, 16, 20, 0, 0, time.UTC),
+		time.Date(2017, 6, 1, 10, 0, 0, 0, time.UTC), true},
+	{"", time.Date(2017, 6, 1, 10, 0, 0, 0, time.UTC), time.Date(2017, 6, 1, 10, 6, 0, 0, time.UTC),
+		time.Time{}, false},
+	{"", time.Date(2017, 5, 31, 10, 0, 0, 0, time.UTC), time.Date(2017, 6, 1, 9, 59, 0, 0, time.UTC),
+		time.Time{}, false},
+	{"", time.Date(2017, 5, 20, 10, 0, 0, 0, time.UTC), time.Date(2017, 6, 1, 9, 0, 0, 0, time.UTC),
+		time.Date(2017, 5, 31, 10, 0, 0, 0, time.UTC), true},
+	{"0 10 * * MON; 0 10 * * THU", time.Date(2017, 5, 29, 10, 0, 0, 0, time.UTC),
+		time.Date(2017, 6, 1, 10, 0, 30, 0, time.UTC), time.Date(2017, 6, 1, 10, 0, 0, 0, time.UTC), true},
+	{"0 10 * * MON; 0 10 * * THU", time.Date(2017, 6, 1, 10, 0, 0, 0, time.UTC),
+		time.Date(2017, 6, 4, 10, 0, 0, 0, time.UTC), time.Time{}, false},
+	{"0 10 * * *", time.Date(2017, 5, 31, 9, 0, 0, 0, time.UTC),
+		time.Date(2017, 6, 1, 10, 0, 0, 0, time.FixedZone("BST", 3600)),
+		time.Date(2017, 6, 1, 10, 0, 0, 0, time.FixedZone("BST", 3600)), true},
+}
+
+func TestDueOccurrence(t *testing.T) {
+	for _, dueOccurrence := range dueOccurrences {
+		schedule, err := parseSchedule(dueOccurrence.scheduleString)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if occurrence, due := schedule.dueOccurrence(dueOccurrence.lastFired, dueOccurrence.now); due !=
+			dueOccurrence.due || !occurrence.Equal(dueOccurrence.occurrence) {
+			t.Errorf("dueOccurrence(%s, %s) for %s returned %s, %t, expected %s, %t", dueOccurrence.lastFired,
+				dueOccurrence.now, dueOccurrence.scheduleString, occurrence, due, dueOccurrence.occurrence,
+				dueOccurrence.due)
+		}
+	}
+}
+
+func TestFullReportDue(t *testing.T) {
+	schedule, _ := parseSchedule("")
+	alertState := newAlertState()
+	now := time.Date(2017, 6, 1, 9, 0, 0, 0, time.UTC)
+	var fullReports = []struct {
+		now time.Time
+		due bool
+	}{
+		{now, false},
+		{now.Add(59 * time.Minute), false},
+		{now.Add(time.Hour), true},
+		{now.Add(time.Hour + time.Minute), false},
+		{now.Add(time.Hour + 6*time.Minute), false},
+		{now.Add(49 * time.Hour), true},
+		{now.Add(49 * time.Hour), false},
+	}
+	for i, fullReport := range fullReports {
+		occurrence, due := alertState.fullReportDue("slack", schedule, fullReport.now)
+		if due != fullReport.due {
+			t.Errorf("fullReportDue %d at %s returned %t, expected %t", i, fullReport.now, due, fullReport.due)
+		}
+		if due {
+			alertState.recordFullReport("slack", occurrence)
+		}
+	}
+	//until it's recorded as sent, the occurrence stays due
+	for i := 0; i < 2; i++ {
+		if _, due := alertState.fullReportDue("slack", schedule, now.Add(73*time.Hour)); !due {
+			t.Errorf("fullReportDue %d for an unrecorded occurrence returned false", i)
+		}
+	}
+}
+
+var sleepingTimes = []struct {
+	now           time.Time
+	nextScheduled time.Time
+	expected      int
+}{
+	{time.Date(2017, 6, 1, 9, 0, 0, 0, time.UTC), time.Date(2017, 6, 1, 10, 0, 0, 0, time.UTC), 60},
+	{time.Date(2017, 6, 1, 9, 59, 30, 0, time.UTC), time.Date(2017, 6, 1, 10, 0, 0, 0, time.UTC), 31},
+	{time.Date(2017, 6, 1, 10, 0, 0, 0, time.UTC), time.Date(2017, 6, 1, 10, 0, 0, 0, time.UTC), 1},
+	{time.Date(2017, 6, 1, 9, 0, 0, 0, time.UTC), time.Time{}, 60},
+}
+
+func TestSleepTime(t *testing.T) {
+	for _, sleepingTime := range sleepingTimes {
+		if v := sleepTime(sleepingTime.now, sleepingTime.nextScheduled); v != sleepingTime.expected {
+			t.Errorf("sleepTime(%s, %s) returned %d, expected %d", sleepingTime.now, sleepingTime.nextScheduled,
+				v, sleepingTime.expected)
+		}
+	}
+}
+
+func TestOutputSchedules(t *testing.T) {
+	schedules, err := outputSchedules("1430", map[string]string{"slack": "", "jira": "monday|0900"})
+	if err != nil || schedules["slack"].Spec != "1430" || schedules["jira"].Spec != "monday|0900" {
+		t.Errorf("outputSchedules returned %+v, %v, expected slack to default to 1430", schedules, err)
+	}
+	if _, err := outputSchedules("1430", map[string]string{"jira": "banana"}); err == nil {
+		t.Errorf("outputSchedules returned no error for an invalid jira schedule")
+	}
+}
@@ -0,0 +1,290 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+//Block Kit limits, see https://api.slack.com/reference/block-kit/blocks
+const (
+	maxSlackBlocks       = 50
+	maxSlackHeaderChars  = 150
+	maxSlackSectionChars = 3000
+	maxSlackFieldChars   = 2000
+	//maxSlackFieldValueChars leaves room in a field for its name, and a " + [x] more" suffix
+	maxSlackFieldValueChars = maxSlackFieldChars - 100
+	//maxSlackMessageBytes keeps the blocks of a message comfortably under the payload size slack rejects
+	maxSlackMessageBytes = 30000
+)
+
+//upgradeClassEmojis are shown next to each image, for the most significant class of upgrade it has available
+var upgradeClassEmojis = map[string]string{
+	"major":   ":red_circle:",
+	"minor":   ":large_orange_circle:",
+	"patch":   ":large_blue_circle:",
+	"unknown": ":white_circle:",
+}
+
+//SlackBlock type representing a Block Kit layout block (header, section, context or divider)
+type SlackBlock struct {
+	Type     string      `json:"type"`
+	Text     *SlackText  `json:"text,omitempty"`
+	Fields   []SlackText `json:"fields,omitempty"`
+	Elements []SlackText `json:"elements,omitempty"`
+}
+
+//SlackText type representing a Block Kit text object. Type is plain_text or mrkdwn
+type SlackText struct {
+	Type string `json:"type"`
+	Text string `json:"text"`
+}
+
+//slackMessages returns the specified results as Block Kit messages: a header summarising them, then a section for each
+// image, grouped by cluster and namespace. The blocks are split across as many messages as it takes to keep each of
+// them within slack's limits, with the header's text (which is also each message's notification text) numbering them
+func slackMessages(upgradeMap map[string][]InspectrResult, fullReport bool) (slackMsgs []SlackMsg) {
+	header := slackHeader(upgradeMap, fullReport)
+	units := append([][]SlackBlock{slackHeaderBlocks(header, slackSummary(upgradeMap))},
+		slackSectionUnits(upgradeMap)...)
+	slackMsgs = slackPartMessages(header, splitSlackBlocks(units))
+	return
+}
+
+//slackHeaderBlocks returns a header block with the specified text, followed by the summary
+func slackHeaderBlocks(header, summary string) (blocks []SlackBlock) {
+	blocks = []SlackBlock{
+		{Type: "header", Text: &SlackText{"plain_text", truncatedString(header, maxSlackHeaderChars)}},
+		{Type: "context", Elements: []SlackText{{"mrkdwn", summary}}},
+	}
+	return
+}
+
+//slackSectionUnits returns a section for each of the specified results' images, grouped by cluster and namespace.
+// Each unit is a section, preceded by a heading if it's the first in its group
+func slackSectionUnits(upgradeMap map[string][]InspectrResult) (units [][]SlackBlock) {
+	group := ""
+	for _, k := range sortedSlackKeys(upgradeMap) {
+		v := upgradeMap[k]
+		var unit []SlackBlock
+		if g := slackGroup(k, v); g != group {
+			group = g
+			unit = append(unit, SlackBlock{Type: "divider"},
+				SlackBlock{Type: "context", Elements: []SlackText{{"mrkdwn", group}}})
+		}
+		units = append(units, append(unit, slackImageSection(k, v)))
+	}
+	return
+}
+
+//slackPartMessages returns a message for each of the specified messages' worth of blocks, with the header as their
+// text, numbering them if there's more than one
+func slackPartMessages(header string, messageBlocks [][]SlackBlock) (slackMsgs []SlackMsg) {
+	for i, blocks := range messageBlocks {
+		text := header
+		if len(messageBlocks) > 1 {
+			part := "part " + strconv.Itoa(i+1) + " of " + strconv.Itoa(len(messageBlocks))
+			text += " (" + part + ")"
+			if i > 0 {
+				blocks = append([]SlackBlock{{Type: "context", Elements: []SlackText{{"mrkdwn",
+					"_" + truncatedString(header, maxSlackHeaderChars) + ", " + part + "_"}}}}, blocks...)
+			}
+		}
+		slackMsgs = append(slackMsgs, SlackMsg{Text: text, Username: "inspectr", Blocks: blocks})
+	}
+	return
+}
+
+//splitSlackBlocks packs the units of blocks (which are kept together) into as few messages' worth of blocks as
+// possible, leaving room in each for a block saying which part of the whole it is
+func splitSlackBlocks(units [][]SlackBlock) (messageBlocks [][]SlackBlock) {
+	var blocks []SlackBlock
+	size := 0
+	for _, unit := range units {
+		unitSize := 0
+		for _, block := range unit {
+			blockJSON, _ := json.Marshal(block)
+			unitSize += len(blockJSON)
+		}
+		if len(blocks) > 0 && (len(blocks)+len(unit) > maxSlackBlocks-1 || size+unitSize > maxSlackMessageBytes) {
+			messageBlocks = append(messageBlocks, blocks)
+			blocks = nil
+			size = 0
+		}
+		blocks = append(blocks, unit...)
+		size += unitSize
+	}
+	if len(blocks) > 0 {
+		messageBlocks = append(messageBlocks, blocks)
+	}
+	return
+}
+
+//slackHeader returns the title of a message with the specified results
+func slackHeader(upgradeMap map[string][]InspectrResult, fullReport bool) (header string) {
+	switch {
+	case fullReport && len(upgradeMap) == 0:
+		header = "inspectr report: no upgrades available"
+	case fullReport:
+		header = "inspectr report: " + countString(len(upgradeMap), "image") + " with upgrades available"
+	default:
+		header = "inspectr: new upgrades for " + countString(len(upgradeMap), "image")
+	}
+	return
+}
+
+//slackSummary returns a line summarising the specified results: how many images have each class of upgrade, and
+// across how many clusters
+func slackSummary(upgradeMap map[string][]InspectrResult) (summary string) {
+	classCounts, clusters := summaryCounts(upgradeMap)
+	var classStrings []string
+	for _, class := range validUpgradeClasses {
+		if classCounts[class] > 0 {
+			classStrings = append(classStrings, upgradeClassEmojis[class]+" "+strconv.Itoa(classCounts[class])+
+				" "+class)
+		}
+	}
+	summary = "nothing to upgrade"
+	if len(classStrings) > 0 {
+		summary = strings.Join(classStrings, "  ") + "  across " + countString(clusters, "cluster")
+	}
+	return
+}
+
+//sortedSlackKeys returns the keys of the specified results, sorted so that results in the same cluster and namespaces
+// are next to each other
+func sortedSlackKeys(upgradeMap map[string][]InspectrResult) (keys []string) {
+	for k := range upgradeMap {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		groupI, groupJ := slackGroup(keys[i], upgradeMap[keys[i]]), slackGroup(keys[j], upgradeMap[keys[j]])
+		if groupI != groupJ {
+			return groupI < groupJ
+		}
+		return keys[i] < keys[j]
+	})
+	return
+}
+
+//slackGroup returns the heading the results (under the specified results map key) are grouped under: their cluster
+// and namespaces
+func slackGroup(mapKey string, inspectrResults []InspectrResult) (group string) {
+	group = "*" + projectFromInspectrMapKey(mapKey) + "/" + clusterFromInspectrMapKey(mapKey) + "*  " +
+		slackFieldString(namespacesFromInspectrResults(inspectrResults))
+	return
+}
+
+//slackImageSection returns a section detailing the results for an image (under the specified results map key)
+func slackImageSection(mapKey string, inspectrResults []InspectrResult) (section SlackBlock) {
+	class := mostSignificantUpgradeClass(inspectrResults)
+	var versions, behind []string
+	for _, inspectrResult := range inspectrResults {
+		versions = append(versions, inspectrResult.Version+" → "+inspectrResult.LatestVersion)
+		behind = append(behind, countString(inspectrResult.VersionsBehind, "version")+", "+
+			daysBehindString(inspectrResult)+" days")
+	}
+	text := upgradeClassEmojis[class] + " *" + imageFromInspectrMapKey(mapKey) + "*  (" + class + " upgrade)"
+	section = SlackBlock{Type: "section", Text: &SlackText{"mrkdwn", truncatedString(text, maxSlackSectionChars)}}
+	fields := []struct {
+		name   string
+		values []string
+	}{
+		{"workload", []string{podFromInspectrMapKey(mapKey) + "/" + containerFromInspectrMapKey(mapKey)}},
+		{"namespaces", namespacesFromInspectrResults(inspectrResults)},
+		{"current → new", versions},
+		{"behind", behind},
+		{"owner", ownersFromInspectrResults(inspectrResults)},
+	}
+	for _, field := range fields {
+		if len(field.values) > 0 {
+			section.Fields = append(section.Fields, SlackText{"mrkdwn", "*" + field.name + "*\n" +
+				slackFieldString(field.values)})
+		}
+	}
+	return
+}
+
+//summaryCounts returns how many of the specified results' images have each class of upgrade (see
+// mostSignificantUpgradeClass), and how many clusters they're across, for each output to summarise them in its own way
+func summaryCounts(upgradeMap map[string][]InspectrResult) (classCounts map[string]int, clusters int) {
+	classCounts = make(map[string]int)
+	clusterSet := make(map[string]struct{})
+	for k, v := range upgradeMap {
+		classCounts[mostSignificantUpgradeClass(v)]++
+		clusterSet[projectFromInspectrMapKey(k)+"/"+clusterFromInspectrMapKey(k)] = struct{}{}
+	}
+	clusters = len(clusterSet)
+	return
+}
+
+//mostSignificantUpgradeClass returns the most significant class of upgrade (major, then minor, then patch) of the
+// specified results, or unknown
+func mostSignificantUpgradeClass(inspectrResults []InspectrResult) (class string) {
+	rank := len(validUpgradeClasses) - 1
+	for _, inspectrResult := range inspectrResults {
+		for i, validClass := range validUpgradeClasses {
+			if inspectrResult.UpgradeClass == validClass && i < rank {
+				rank = i
+			}
+		}
+	}
+	class = validUpgradeClasses[rank]
+	return
+}
+
+//ownersFromInspectrResults returns the distinct teams owning the workloads in the InspectrResult slice
+func ownersFromInspectrResults(inspectrResults []InspectrResult) (owners []string) {
+	for _, inspectrResult := range inspectrResults {
+		if team := inspectrResult.Ownership.Team; team != "" && !contains(owners, team) {
+			owners = append(owners, team)
+		}
+	}
+	return
+}
+
+//slackFieldString returns a comma separated string of the distinct values, with as many of them as fit in a Block Kit
+// field followed by " + [x] more" for any that don't
+func slackFieldString(values []string) (fieldString string) {
+	var distinct []string
+	for _, value := range values {
+		if !contains(distinct, value) {
+			distinct = append(distinct, value)
+		}
+	}
+	var buffer bytes.Buffer
+	for i, value := range distinct {
+		if buffer.Len()+len(value)+2 > maxSlackFieldValueChars {
+			buffer.WriteString(" + ")
+			buffer.WriteString(strconv.Itoa(len(distinct) - i))
+			buffer.WriteString(" more")
+			break
+		}
+		if i != 0 {
+			buffer.WriteString(", ")
+		}
+		buffer.WriteString(value)
+	}
+	fieldString = buffer.String()
+	return
+}
+
+//truncatedString returns the string cut down to at most max characters, ending in "…" if it had to be cut
+func truncatedString(s string, max int) (truncated string) {
+	truncated = s
+	if runes := []rune(s); len(runes) > max {
+		truncated = string(runes[:max-1]) + "…"
+	}
+	return
+}
+
+//countString returns the count followed by the noun, pluralised if the count isn't 1, e.g. "2 images"
+func countString(count int, noun string) (countString string) {
+	countString = strconv.Itoa(count) + " " + noun
+	if count != 1 {
+		countString += "s"
+	}
+	return
+}
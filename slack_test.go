@@ -0,0 +1,163 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSlackMessages(t *testing.T) {
+	upgradeMap := map[string][]InspectrResult{
+		"project:prod:eversc/app:app:app": {{Namespace: "web", Version: "1.0.0", LatestVersion: "1.0.1",
+			UpgradeClass: "patch", VersionsBehind: 1, DaysBehind: 3}},
+		"project:prod:eversc/db:db:db": {{Namespace: "data", Version: "1.0.0", LatestVersion: "2.0.0",
+			UpgradeClass: "major", VersionsBehind: 4, DaysBehind: -1, Ownership: Ownership{Team: "storage"}}},
+	}
+	slackMsgs := slackMessages(upgradeMap, true)
+	if len(slackMsgs) != 1 {
+		t.Fatalf("slackMessages returned %d messages, expected 1", len(slackMsgs))
+	}
+	blocks := slackMsgs[0].Blocks
+	if slackMsgs[0].Text != "inspectr report: 2 images with upgrades available" || blocks[0].Type != "header" {
+		t.Errorf("slackMessages returned header %q, %#v", slackMsgs[0].Text, blocks[0])
+	}
+	if v := blocks[1].Elements[0].Text; v != ":red_circle: 1 major  :large_blue_circle: 1 patch  across 1 cluster" {
+		t.Errorf("slackMessages returned summary %s", v)
+	}
+	//data sorts before web, so the db image comes first
+	if v := blocks[4].Text.Text; v != ":red_circle: *eversc/db*  (major upgrade)" {
+		t.Errorf("slackMessages returned first image section %s", v)
+	}
+	if v := blocks[4].Fields[3].Text; v != "*behind*\n4 versions, ? days" {
+		t.Errorf("slackMessages returned behind field %s", v)
+	}
+	if len(blocks[4].Fields) != 5 || len(blocks[7].Fields) != 4 {
+		t.Errorf("slackMessages returned %d and %d fields, expected an owner field only for the db image",
+			len(blocks[4].Fields), len(blocks[7].Fields))
+	}
+	if v := slackMessages(nil, false)[0].Text; v != "inspectr: new upgrades for 0 images" {
+		t.Errorf("slackMessages returned header %s for no new findings", v)
+	}
+}
+
+func TestSlackMessagesSplit(t *testing.T) {
+	upgradeMap := make(map[string][]InspectrResult)
+	for i := 0; i < 120; i++ {
+		key := "project:prod:eversc/app" + strconv.Itoa(i) + ":app:app"
+		upgradeMap[key] = []InspectrResult{{Namespace: "ns" + strconv.Itoa(i%3), Version: "1.0.0",
+			LatestVersion: "1.1.0", UpgradeClass: "minor"}}
+	}
+	slackMsgs := slackMessages(upgradeMap, true)
+	if len(slackMsgs) < 3 {
+		t.Fatalf("slackMessages returned %d messages for 120 images, expected them split", len(slackMsgs))
+	}
+	sections := 0
+	for i, slackMsg := range slackMsgs {
+		payload, _ := json.Marshal(slackMsg)
+		if len(slackMsg.Blocks) > maxSlackBlocks || len(payload) > maxSlackMessageBytes+1000 {
+			t.Errorf("message %d has %d blocks and %d bytes", i, len(slackMsg.Blocks), len(payload))
+		}
+		if !strings.HasSuffix(slackMsg.Text, "(part "+strconv.Itoa(i+1)+" of "+strconv.Itoa(len(slackMsgs))+")") {
+			t.Errorf("message %d has text %s", i, slackMsg.Text)
+		}
+		for _, block := range slackMsg.Blocks {
+			if block.Type == "section" {
+				sections++
+			}
+		}
+	}
+	if sections != 120 {
+		t.Errorf("slackMessages returned %d image sections, expected 120", sections)
+	}
+}
+
+func TestSlackFieldString(t *testing.T) {
+	if v := slackFieldString([]string{"a", "b", "a"}); v != "a, b" {
+		t.Errorf("slackFieldString returned %s, expected a, b", v)
+	}
+	var values []string
+	for i := 0; i < 1000; i++ {
+		values = append(values, "namespace-"+strconv.Itoa(i))
+	}
+	v := slackFieldString(values)
+	if len("*namespaces*\n"+v) > maxSlackFieldChars || !strings.HasSuffix(v, "+ 866 more") {
+		t.Errorf("slackFieldString returned %d characters: %s", len(v), v)
+	}
+}
+
+func TestPostSlackMsg(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/hooks/slow":
+			time.Sleep(200 * time.Millisecond)
+		case "/hooks/gone":
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte("no_service"))
+		}
+	}))
+	defer server.Close()
+	slackConfig := SlackConfig{WebhookID: server.URL + "/hooks/ok", HTTP: HTTPConfig{Timeout: "50ms"}}
+	validateHTTP(&slackConfig.HTTP, "http", func(path, message string) { t.Errorf("%s: %s", path, message) })
+	if err := postStringToSlack("hello", slackConfig); err != nil {
+		t.Errorf("postStringToSlack to a full webhook url returned error %v", err)
+	}
+	slackConfig.WebhookID = server.URL + "/hooks/gone"
+	if err := postStringToSlack("hello", slackConfig); err == nil || err.Error() !=
+		"slack webhook returned status 404: no_service" {
+		t.Errorf("postStringToSlack to a missing webhook returned error %v", err)
+	}
+	slackConfig.WebhookID = server.URL + "/hooks/slow"
+	if err := postStringToSlack("hello", slackConfig); err == nil || strings.Contains(err.Error(), "/hooks/") {
+		t.Errorf("postStringToSlack to a slow webhook returned error %v, expected a timeout without the url", err)
+	}
+	if v := slackWebhookURL("T00/B00/XXX"); v != "https://hooks.slack.com/services/T00/B00/XXX" {
+		t.Errorf("slackWebhookURL returned %s", v)
+	}
+}
+
+func TestOutputResultsIgnoresChannelAnnotation(t *testing.T) {
+	var msgs []SlackMsg
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var msg SlackMsg
+		json.NewDecoder(r.Body).Decode(&msg)
+		msgs = append(msgs, msg)
+	}))
+	defer server.Close()
+	upgradeMap := map[string][]InspectrResult{
+		"project:cluster:eversc/app:app:app": {{Namespace: "a", Upgrades: []string{"v2"}}},
+		"project:cluster:eversc/web:web:web": {{Namespace: "b", Upgrades: []string{"v2"},
+			Ownership: Ownership{SlackChannel: "#b"}}},
+	}
+	if err := outputResults(upgradeMap, SlackConfig{WebhookID: server.URL}, true); err != nil {
+		t.Fatal(err)
+	}
+	if len(msgs) != 1 || msgs[0].Channel != "" {
+		t.Errorf("outputResults posted %+v, expected one message in the webhook's own channel", msgs)
+	}
+}
+
+func TestSummaryCounts(t *testing.T) {
+	upgradeMap := map[string][]InspectrResult{
+		"project:a:eversc/app:app:app": {{UpgradeClass: "major"}, {UpgradeClass: "patch"}},
+		"project:a:eversc/web:web:web": {{UpgradeClass: "patch"}},
+		"project:b:eversc/db:db:db":    {{UpgradeClass: "major"}},
+	}
+	classCounts, clusters := summaryCounts(upgradeMap)
+	if classCounts["major"] != 2 || classCounts["patch"] != 1 || clusters != 2 {
+		t.Errorf("summaryCounts returned %v, %d, expected 2 major and 1 patch across 2 clusters", classCounts, clusters)
+	}
+	if v := teamsSummary(upgradeMap); v != "2 major, 1 patch across 2 clusters" {
+		t.Errorf("teamsSummary returned %q", v)
+	}
+	if v := slackSummary(upgradeMap); !strings.HasSuffix(v, " 2 major  "+upgradeClassEmojis["patch"]+
+		" 1 patch  across 2 clusters") {
+		t.Errorf("slackSummary returned %q", v)
+	}
+	if v := teamsSummary(nil); v != "nothing to upgrade" {
+		t.Errorf("teamsSummary of no results returned %q", v)
+	}
+}
@@ -0,0 +1,239 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+//slackAPIURL is the slack Web API used with a bot token, unless another is configured
+const slackAPIURL = "https://slack.com/api/"
+
+//SlackThread type representing the thread results are posted in, in a slack channel, with a bot token: a parent
+// message summarising the last full report, with the details of its images, and any new findings since, in its thread
+type SlackThread struct {
+	//Channel is the id of the channel, as returned by slack
+	Channel string `json:"channel"`
+	//TS identifies the parent message
+	TS      string `json:"ts"`
+	Header  string `json:"header"`
+	Summary string `json:"summary"`
+	//NewFindings is the number of images with new upgrades posted in the thread since it was started
+	NewFindings int `json:"newFindings"`
+	//MapKeys are the inspectr map keys of the results posted in the thread
+	MapKeys []string `json:"mapKeys"`
+}
+
+//SlackAPIResponse type representing the json schema of a slack Web API response
+type SlackAPIResponse struct {
+	OK      bool   `json:"ok"`
+	Error   string `json:"error"`
+	Channel string `json:"channel"`
+	TS      string `json:"ts"`
+}
+
+//slackAPINotifier type: a Notifier that posts to slack channels through the Web API, with a bot token. Each full
+// report starts a new thread, which new findings are replied in until the next one
+type slackAPINotifier struct {
+	config     SlackConfig
+	receiver   string
+	alertState *AlertState
+}
+
+//slackThreadKey returns the key the receiver's thread in the specified channel is kept under in AlertState
+func slackThreadKey(receiver, channel string) string {
+	return receiver + "/" + channel
+}
+
+//slackAPINotifier implementation of Notifier
+func (notifier *slackAPINotifier) FullReport(upgradeMap map[string][]InspectrResult) error {
+	return notifier.postResults(upgradeMap, true)
+}
+
+//slackAPINotifier implementation of Notifier
+func (notifier *slackAPINotifier) NewFindings(upgradeMap map[string][]InspectrResult) error {
+	return notifier.postResults(upgradeMap, false)
+}
+
+//slackAPINotifier implementation of Notifier. The event is replied in the thread the results it's about were posted
+// in, if there is one
+func (notifier *slackAPINotifier) Event(event NotifierEvent) (err error) {
+	slackMsg := SlackMsg{Text: event.description() + " for " + imageFromInspectrMapKey(event.MapKey),
+		Username: "inspectr", Channel: notifier.config.Channel}
+	if thread := notifier.eventThread(event.MapKey); thread != nil {
+		slackMsg.Channel = thread.Channel
+		slackMsg.ThreadTS = thread.TS
+	}
+	_, err = notifier.call("chat.postMessage", slackMsg)
+	return
+}
+
+//postResults posts the results to the channels their owners want them in (see slackChannelMaps), or else the
+// configured channel. A full report, or results for a channel without a thread yet, start a new thread in the
+// channel, and anything else is replied in its thread
+func (notifier *slackAPINotifier) postResults(upgradeMap map[string][]InspectrResult, fullReport bool) (err error) {
+	channelMaps := make(map[string]map[string][]InspectrResult)
+	for channel, channelMap := range slackChannelMaps(upgradeMap) {
+		if channel == "" {
+			channel = notifier.config.Channel
+		}
+		if channelMaps[channel] == nil {
+			channelMaps[channel] = make(map[string][]InspectrResult)
+		}
+		for k, v := range channelMap {
+			channelMaps[channel][k] = append(channelMaps[channel][k], v...)
+		}
+	}
+	if len(channelMaps) == 0 {
+		channelMaps[notifier.config.Channel] = upgradeMap
+	}
+	for channel, channelMap := range channelMaps {
+		key := slackThreadKey(notifier.receiver, channel)
+		thread, ok := notifier.alertState.SlackThreads[key]
+		var postErr error
+		if fullReport || !ok {
+			thread = &SlackThread{Header: slackHeader(channelMap, fullReport), Summary: slackSummary(channelMap)}
+			postErr = notifier.startThread(thread, channel, channelMap)
+			if thread.TS != "" {
+				notifier.alertState.SlackThreads[key] = thread
+			}
+		} else {
+			postErr = notifier.replyInThread(thread, channelMap)
+		}
+		if postErr != nil {
+			err = postErr
+		}
+	}
+	return
+}
+
+//startThread posts the thread's parent message in the specified channel, and the details of the results in its
+// thread
+func (notifier *slackAPINotifier) startThread(thread *SlackThread, channel string,
+	upgradeMap map[string][]InspectrResult) (err error) {
+
+	thread.addMapKeys(upgradeMap)
+	var response SlackAPIResponse
+	response, err = notifier.call("chat.postMessage", SlackMsg{Text: thread.Header, Username: "inspectr",
+		Channel: channel, Blocks: thread.blocks()})
+	if err == nil {
+		thread.Channel = response.Channel
+		thread.TS = response.TS
+		err = notifier.postReplies(thread, thread.Header, upgradeMap)
+	}
+	return
+}
+
+//replyInThread posts the details of new findings in the thread, and updates its parent message to say they're there
+func (notifier *slackAPINotifier) replyInThread(thread *SlackThread, upgradeMap map[string][]InspectrResult) (
+	err error) {
+
+	err = notifier.postReplies(thread, slackHeader(upgradeMap, false), upgradeMap)
+	if err == nil {
+		thread.NewFindings += len(upgradeMap)
+		thread.addMapKeys(upgradeMap)
+		_, err = notifier.call("chat.update", SlackMsg{Text: thread.Header, Channel: thread.Channel, TS: thread.TS,
+			Blocks: thread.blocks()})
+	}
+	return
+}
+
+//postReplies posts a section for each of the results' images in the thread, split across as many messages as it
+// takes (see slackSectionUnits)
+func (notifier *slackAPINotifier) postReplies(thread *SlackThread, header string,
+	upgradeMap map[string][]InspectrResult) (err error) {
+
+	for _, slackMsg := range slackPartMessages(header, splitSlackBlocks(slackSectionUnits(upgradeMap))) {
+		slackMsg.Channel = thread.Channel
+		slackMsg.ThreadTS = thread.TS
+		_, err = notifier.call("chat.postMessage", slackMsg)
+		if err != nil {
+			break
+		}
+	}
+	return
+}
+
+//eventThread returns the receiver's thread that results under the specified map key were posted in, or else its
+// thread in the configured channel, or nil if it doesn't have one
+func (notifier *slackAPINotifier) eventThread(mapKey string) (thread *SlackThread) {
+	var keys []string
+	for key := range notifier.alertState.SlackThreads {
+		if strings.HasPrefix(key, notifier.receiver+"/") {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		if contains(notifier.alertState.SlackThreads[key].MapKeys, mapKey) {
+			thread = notifier.alertState.SlackThreads[key]
+			break
+		}
+	}
+	if thread == nil {
+		thread = notifier.alertState.SlackThreads[slackThreadKey(notifier.receiver, notifier.config.Channel)]
+	}
+	return
+}
+
+//call calls the named slack Web API method with the message, returning an error if slack doesn't respond ok
+func (notifier *slackAPINotifier) call(method string, slackMsg SlackMsg) (response SlackAPIResponse, err error) {
+	apiURL := notifier.config.APIURL
+	if apiURL == "" {
+		apiURL = slackAPIURL
+	}
+	if !strings.HasSuffix(apiURL, "/") {
+		apiURL += "/"
+	}
+	body, _ := json.Marshal(slackMsg)
+	var req *http.Request
+	req, err = http.NewRequest("POST", apiURL+method, bytes.NewReader(body))
+	if err == nil {
+		req.Header.Set("Content-Type", "application/json; charset=utf-8")
+		req.Header.Set("Authorization", "Bearer "+notifier.config.Token)
+		var resp *http.Response
+		resp, err = notifier.config.HTTP.httpClient().Do(req)
+		if err == nil {
+			defer resp.Body.Close()
+			if resp.StatusCode != http.StatusOK {
+				err = errors.New("slack " + method + " returned status " + strconv.Itoa(resp.StatusCode))
+				if retryAfter := resp.Header.Get("Retry-After"); retryAfter != "" {
+					err = errors.New(err.Error() + ", retry after " + retryAfter + " seconds")
+				}
+			} else {
+				err = json.NewDecoder(resp.Body).Decode(&response)
+				if err == nil && !response.OK {
+					err = errors.New("slack " + method + " failed: " + response.Error)
+				}
+			}
+		}
+	}
+	return
+}
+
+//blocks returns the thread's parent message: the header and summary of the results it was started with, and where
+// to find their details
+func (thread *SlackThread) blocks() (blocks []SlackBlock) {
+	blocks = slackHeaderBlocks(thread.Header, thread.Summary)
+	if len(thread.MapKeys) > 0 {
+		details := "details in the thread"
+		if thread.NewFindings > 0 {
+			details += ", along with new upgrades for " + countString(thread.NewFindings, "image") + " since"
+		}
+		blocks = append(blocks, SlackBlock{Type: "context", Elements: []SlackText{{"mrkdwn", details}}})
+	}
+	return
+}
+
+//addMapKeys records that the specified results have been posted in the thread
+func (thread *SlackThread) addMapKeys(upgradeMap map[string][]InspectrResult) {
+	for k := range upgradeMap {
+		if !contains(thread.MapKeys, k) {
+			thread.MapKeys = append(thread.MapKeys, k)
+		}
+	}
+}
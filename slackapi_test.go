@@ -0,0 +1,91 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+//fakeSlackAPI type: a local stand in for the slack Web API, recording the messages it's sent. Channels starting
+// "#gone" don't exist
+type fakeSlackAPI struct {
+	calls []struct {
+		method   string
+		slackMsg SlackMsg
+	}
+}
+
+func (api *fakeSlackAPI) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var slackMsg SlackMsg
+	json.NewDecoder(r.Body).Decode(&slackMsg)
+	method := strings.TrimPrefix(r.URL.Path, "/api/")
+	api.calls = append(api.calls, struct {
+		method   string
+		slackMsg SlackMsg
+	}{method, slackMsg})
+	response := SlackAPIResponse{OK: true, Channel: "C" + strings.TrimPrefix(slackMsg.Channel, "#"),
+		TS: "1500000000." + strconv.Itoa(len(api.calls))}
+	switch {
+	case r.Header.Get("Authorization") != "Bearer xoxb-test":
+		response = SlackAPIResponse{Error: "invalid_auth"}
+	case strings.HasPrefix(slackMsg.Channel, "#gone"):
+		response = SlackAPIResponse{Error: "channel_not_found"}
+	}
+	json.NewEncoder(w).Encode(response)
+}
+
+func TestSlackAPINotifier(t *testing.T) {
+	api := &fakeSlackAPI{}
+	server := httptest.NewServer(api)
+	defer server.Close()
+	alertState := newAlertState()
+	notifier := &slackAPINotifier{config: SlackConfig{Token: "xoxb-test", Channel: "#upgrades",
+		APIURL: server.URL + "/api"}, receiver: defaultReceiver, alertState: alertState}
+	appKey := "project:cluster:eversc/app:app:app"
+	dbKey := "project:cluster:eversc/db:db:db"
+	err := notifier.FullReport(map[string][]InspectrResult{appKey: {{Namespace: "default", UpgradeClass: "minor"}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	thread := alertState.SlackThreads["default/#upgrades"]
+	if len(api.calls) != 2 || thread == nil || thread.Channel != "Cupgrades" || thread.TS != "1500000000.1" ||
+		api.calls[1].slackMsg.ThreadTS != thread.TS {
+		t.Fatalf("full report made calls %+v, leaving thread %+v", api.calls, thread)
+	}
+	err = notifier.NewFindings(map[string][]InspectrResult{dbKey: {{Namespace: "default", UpgradeClass: "major"}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(api.calls) != 4 || api.calls[2].slackMsg.ThreadTS != thread.TS || api.calls[3].method != "chat.update" ||
+		api.calls[3].slackMsg.TS != thread.TS || thread.NewFindings != 1 {
+		t.Errorf("new findings made calls %+v, leaving thread %+v", api.calls[2:], thread)
+	}
+	parentBlocks := api.calls[3].slackMsg.Blocks
+	if v := parentBlocks[len(parentBlocks)-1].Elements[0].Text; !strings.HasSuffix(v, "new upgrades for 1 image since") {
+		t.Errorf("chat.update set the parent's details to %s", v)
+	}
+	err = notifier.Event(NotifierEvent{"jira", jiraCreatedEvent, dbKey, "https://jira.example.com/browse/OPS-1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v := api.calls[4].slackMsg; v.ThreadTS != thread.TS ||
+		v.Text != "just created https://jira.example.com/browse/OPS-1 for eversc/db" {
+		t.Errorf("event was posted as %+v", v)
+	}
+	err = notifier.FullReport(map[string][]InspectrResult{appKey: {{Namespace: "default"}},
+		dbKey: {{Namespace: "gone", Ownership: Ownership{SlackChannel: "#gone"}}}})
+	if err == nil || !strings.Contains(err.Error(), "channel_not_found") {
+		t.Errorf("full report to a missing channel returned error %v", err)
+	}
+	if v := alertState.SlackThreads["default/#upgrades"]; v == thread || v.NewFindings != 0 {
+		t.Errorf("second full report left thread %+v, expected a new one", v)
+	}
+	notifier.config.Token = "xoxb-wrong"
+	if err = notifier.NewFindings(map[string][]InspectrResult{appKey: {{}}}); err == nil ||
+		!strings.Contains(err.Error(), "invalid_auth") {
+		t.Errorf("new findings with the wrong token returned error %v", err)
+	}
+}
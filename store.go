@@ -0,0 +1,485 @@
+package main
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/golang/glog"
+)
+
+//AlertState type representing what inspectr has alerted on, so that it can survive restarts
+type AlertState struct {
+	//Outputs is keyed by output name and receiver, see outputStateKey, e.g. "slack" or "slack/payments"
+	Outputs map[string]*OutputState `json:"outputs"`
+	//SlackThreads is keyed by receiver and channel, see slackThreadKey
+	SlackThreads map[string]*SlackThread `json:"slackThreads,omitempty"`
+	//FiringAlerts are the alerts each receiver's alertmanager has been sent that haven't resolved, keyed by receiver
+	FiringAlerts map[string][]AlertmanagerAlert `json:"firingAlerts,omitempty"`
+	//JiraIssues are the JIRA issues each receiver's jira output is tracking so it can close them, keyed by receiver and
+	// then inspectr map key
+	JiraIssues map[string]map[string]*JiraIssue `json:"jiraIssues,omitempty"`
+}
+
+//JiraIssue type representing a JIRA issue inspectr created (or commented on) for an image, and whether it's since
+// closed it
+type JiraIssue struct {
+	Key    string `json:"key"`
+	Closed bool   `json:"closed"`
+}
+
+//OutputState type representing what a single output has alerted on
+type OutputState struct {
+	//RegisteredImages is the alert cache, see registeredImageString
+	RegisteredImages map[string][]string `json:"registeredImages"`
+	//Alerted is when each inspectr map key was last alerted on
+	Alerted map[string]time.Time `json:"alerted"`
+	//LastFullReport is the scheduled time of the last full report
+	LastFullReport time.Time `json:"lastFullReport"`
+}
+
+//AlertStore type representing somewhere an AlertState can be persisted, along with the leader's latest Scan so that
+// followers can serve it too. The scan is stored separately from the alert state (in its own file or
+// ConfigMap/Secret), so failing to save it, e.g. because it's too big, doesn't stop the alert state being saved
+type AlertStore interface {
+	Load() (*AlertState, error)
+	Save(*AlertState) error
+	LoadScan() (*Scan, error)
+	SaveScan(*Scan) error
+}
+
+//memoryStore type: an AlertStore that doesn't persist anything, so the alert cache is lost on restart
+type memoryStore struct{}
+
+//fileStore type: an AlertStore that persists to a local file, e.g. on a persistent volume
+type fileStore struct {
+	path      string
+	lastSaved []byte
+}
+
+//k8sStore type: an AlertStore that persists to a k8s ConfigMap or Secret
+type k8sStore struct {
+	api       *K8sAPI
+	kind      string
+	namespace string
+	name      string
+	lastSaved []byte
+}
+
+//K8sStateObject type representing the json schema of the ConfigMap/Secret the alert state is stored in. For Secrets,
+// Data values are base64 encoded
+type K8sStateObject struct {
+	APIVersion string `json:"apiVersion"`
+	Kind       string `json:"kind"`
+	Metadata   struct {
+		Name            string `json:"name"`
+		Namespace       string `json:"namespace"`
+		ResourceVersion string `json:"resourceVersion,omitempty"`
+	} `json:"metadata"`
+	Data map[string]string `json:"data"`
+}
+
+//k8sStatePatch type representing the json merge patch that updates a ConfigMap/Secret's data. Only the one data key
+// is written, so labels, annotations and any other keys on the object are left as they are
+type k8sStatePatch struct {
+	Metadata struct {
+		ResourceVersion string `json:"resourceVersion"`
+	} `json:"metadata"`
+	Data map[string]string `json:"data"`
+}
+
+const (
+	//stateDataKey is the key the alert state is stored under in a ConfigMap or Secret
+	stateDataKey = "state.json"
+	//scanDataKey is the key the latest scan is stored under in its ConfigMap or Secret, see k8sStore.scanName
+	scanDataKey = "scan.json"
+)
+
+//newAlertState returns an empty AlertState
+func newAlertState() *AlertState {
+	return &AlertState{Outputs: make(map[string]*OutputState), SlackThreads: make(map[string]*SlackThread),
+		FiringAlerts: make(map[string][]AlertmanagerAlert), JiraIssues: make(map[string]map[string]*JiraIssue)}
+}
+
+//outputState returns the state for the named output, creating it if it doesn't exist
+func (alertState *AlertState) outputState(output string) *OutputState {
+	outputState, ok := alertState.Outputs[output]
+	if !ok {
+		outputState = &OutputState{}
+		alertState.Outputs[output] = outputState
+	}
+	if outputState.RegisteredImages == nil {
+		outputState.RegisteredImages = make(map[string][]string)
+	}
+	if outputState.Alerted == nil {
+		outputState.Alerted = make(map[string]time.Time)
+	}
+	return outputState
+}
+
+//registerAlerts returns the upgradeMap filtered down to what the named output hasn't already alerted on (everything,
+// if withinAlertWindow), and records that in the output's alert cache
+func (alertState *AlertState) registerAlerts(output string, upgradeMap map[string][]InspectrResult,
+	withinAlertWindow bool, now time.Time) (filteredMap map[string][]InspectrResult) {
+
+	outputState := alertState.outputState(output)
+	filteredMap = filterUpgradesMap(upgradeMap, outputState.RegisteredImages, withinAlertWindow)
+	outputState.RegisteredImages = augmentInternalImageRegistry(filteredMap, outputState.RegisteredImages,
+		withinAlertWindow)
+	for k := range filteredMap {
+		outputState.Alerted[k] = now
+	}
+	for k := range outputState.Alerted {
+		if _, ok := outputState.RegisteredImages[k]; !ok {
+			delete(outputState.Alerted, k)
+		}
+	}
+	return
+}
+
+//forgetAlerts removes the specified results from the named output's alert cache, e.g. because the output failed to
+// send them, so that they're sent again as new findings
+func (alertState *AlertState) forgetAlerts(output string, upgradeMap map[string][]InspectrResult) {
+	outputState := alertState.outputState(output)
+	for k, v := range upgradeMap {
+		forgotten := stringSliceFromResultSlice(v)
+		var registeredResults []string
+		for _, registeredResult := range outputState.RegisteredImages[k] {
+			if !contains(forgotten, registeredResult) {
+				registeredResults = append(registeredResults, registeredResult)
+			}
+		}
+		if len(registeredResults) > 0 {
+			outputState.RegisteredImages[k] = registeredResults
+		} else {
+			delete(outputState.RegisteredImages, k)
+			delete(outputState.Alerted, k)
+		}
+	}
+}
+
+//fullReportDue returns the occurrence of the named output's schedule that's come round since its last full report,
+// and a bool indicating whether there is one. It isn't recorded as reported until recordFullReport is called, once the
+// report's been sent, so a report that fails is retried after the next scan. On the very first run nothing is due (the
+// alert cache is empty, so everything gets output anyway), and the current time is recorded as the starting point
+func (alertState *AlertState) fullReportDue(output string, schedule *Schedule, now time.Time) (occurrence time.Time,
+	due bool) {
+
+	outputState := alertState.outputState(output)
+	if outputState.LastFullReport.IsZero() {
+		outputState.LastFullReport = now
+	} else {
+		occurrence, due = schedule.dueOccurrence(outputState.LastFullReport, now)
+		if due && now.Sub(occurrence) > scanInterval {
+			glog.Infof("catching up on %s full report scheduled for %s", output, occurrence)
+		}
+	}
+	return
+}
+
+//recordFullReport records the named output's full report for the specified occurrence of its schedule as sent
+func (alertState *AlertState) recordFullReport(output string, occurrence time.Time) {
+	alertState.outputState(output).LastFullReport = occurrence
+}
+
+//alertStoreFromString returns the AlertStore described by the specified string, which should be one of:
+//
+//     ""                            (not persisted)
+//     file:[path]
+//     configmap:[namespace]/[name]
+//     secret:[namespace]/[name]
+func alertStoreFromString(storeString string) (alertStore AlertStore, err error) {
+	storeSplit := strings.SplitN(storeString, ":", 2)
+	switch {
+	case storeString == "":
+		alertStore = memoryStore{}
+	case len(storeSplit) == 2 && storeSplit[0] == "file" && storeSplit[1] != "":
+		alertStore = &fileStore{path: storeSplit[1]}
+	case len(storeSplit) == 2 && (storeSplit[0] == "configmap" || storeSplit[0] == "secret"):
+		nameSplit := strings.Split(storeSplit[1], "/")
+		if len(nameSplit) != 2 || nameSplit[0] == "" || nameSplit[1] == "" {
+			err = errors.New("invalid state store " + storeString + ", expected " + storeSplit[0] +
+				":[namespace]/[name]")
+		} else {
+			var api *K8sAPI
+			api, err = inClusterK8sAPI()
+			if err == nil {
+				alertStore = &k8sStore{api: api, kind: storeSplit[0], namespace: nameSplit[0], name: nameSplit[1]}
+			}
+		}
+	default:
+		err = errors.New("invalid state store " + storeString +
+			", expected file:[path], configmap:[namespace]/[name] or secret:[namespace]/[name]")
+	}
+	return
+}
+
+//decodeAlertState returns an AlertState decoded from the specified json, an empty one if there's no json
+func decodeAlertState(data []byte) (alertState *AlertState, err error) {
+	alertState = newAlertState()
+	if len(data) > 0 {
+		err = json.Unmarshal(data, alertState)
+		if alertState.Outputs == nil {
+			alertState.Outputs = make(map[string]*OutputState)
+		}
+		if alertState.SlackThreads == nil {
+			alertState.SlackThreads = make(map[string]*SlackThread)
+		}
+		if alertState.FiringAlerts == nil {
+			alertState.FiringAlerts = make(map[string][]AlertmanagerAlert)
+		}
+		if alertState.JiraIssues == nil {
+			alertState.JiraIssues = make(map[string]map[string]*JiraIssue)
+		}
+	}
+	return
+}
+
+//memoryStore implementation of AlertStore
+func (store memoryStore) Load() (*AlertState, error) {
+	return newAlertState(), nil
+}
+
+//memoryStore implementation of AlertStore
+func (store memoryStore) Save(alertState *AlertState) error {
+	return nil
+}
+
+//memoryStore implementation of AlertStore
+func (store memoryStore) LoadScan() (*Scan, error) {
+	return nil, nil
+}
+
+//memoryStore implementation of AlertStore
+func (store memoryStore) SaveScan(scan *Scan) error {
+	return nil
+}
+
+//fileStore implementation of AlertStore. A missing file is treated as an empty state
+func (store *fileStore) Load() (alertState *AlertState, err error) {
+	var data []byte
+	data, err = readStateFile(store.path)
+	if err == nil {
+		alertState, err = decodeAlertState(data)
+		store.lastSaved = data
+	}
+	return
+}
+
+//fileStore implementation of AlertStore
+func (store *fileStore) Save(alertState *AlertState) (err error) {
+	var data []byte
+	data, err = json.Marshal(alertState)
+	if err == nil && !bytes.Equal(data, store.lastSaved) {
+		err = writeStateFile(store.path, data)
+		if err == nil {
+			store.lastSaved = data
+		}
+	}
+	return
+}
+
+//scanPath returns the path of the file the latest scan is stored in, next to the alert state's
+func (store *fileStore) scanPath() string {
+	return store.path + ".scan"
+}
+
+//fileStore implementation of AlertStore. A missing file is treated as there being no scan yet
+func (store *fileStore) LoadScan() (scan *Scan, err error) {
+	var data []byte
+	data, err = readStateFile(store.scanPath())
+	if err == nil && len(data) > 0 {
+		scan = new(Scan)
+		err = json.Unmarshal(data, scan)
+	}
+	return
+}
+
+//fileStore implementation of AlertStore
+func (store *fileStore) SaveScan(scan *Scan) (err error) {
+	var data []byte
+	data, err = json.Marshal(scan)
+	if err == nil {
+		err = writeStateFile(store.scanPath(), data)
+	}
+	return
+}
+
+//readStateFile returns the contents of the file at path, or nothing if it doesn't exist
+func readStateFile(path string) (data []byte, err error) {
+	data, err = ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		err = nil
+	}
+	return
+}
+
+//writeStateFile writes data to a temporary file in the same directory as path, then renames it over the old one, so
+// a crash part way through never leaves a truncated file behind
+func writeStateFile(path string, data []byte) (err error) {
+	var tmpFile *os.File
+	tmpFile, err = ioutil.TempFile(filepath.Dir(path), filepath.Base(path)+".tmp")
+	if err == nil {
+		_, err = tmpFile.Write(data)
+		if err == nil {
+			err = tmpFile.Sync()
+		}
+		closeErr := tmpFile.Close()
+		if err == nil {
+			err = closeErr
+		}
+		if err == nil {
+			err = os.Rename(tmpFile.Name(), path)
+		}
+		if err != nil {
+			os.Remove(tmpFile.Name())
+		}
+	}
+	return
+}
+
+//path returns the api path of the named ConfigMap/Secret, or of the collection if name is empty
+func (store *k8sStore) path(name string) (path string) {
+	path = "/api/v1/namespaces/" + store.namespace + "/" + store.kind + "s"
+	if name != "" {
+		path += "/" + name
+	}
+	return
+}
+
+//scanName returns the name of the ConfigMap/Secret the latest scan is stored in, which is kept apart from the alert
+// state's so that the scan can't push it over the 1MiB size limit
+func (store *k8sStore) scanName() string {
+	return store.name + "-scan"
+}
+
+//get returns the named ConfigMap/Secret, or nil if it doesn't exist yet
+func (store *k8sStore) get(name string) (object *K8sStateObject, err error) {
+	var resp *http.Response
+	resp, err = store.api.do("GET", store.path(name), nil)
+	if err == nil {
+		defer resp.Body.Close()
+		switch resp.StatusCode {
+		case http.StatusOK:
+			object = new(K8sStateObject)
+			err = json.NewDecoder(resp.Body).Decode(object)
+		case http.StatusNotFound:
+		default:
+			err = k8sStatusError(resp, "GET", store.path(name))
+		}
+	}
+	return
+}
+
+//read returns the data stored under key in the named ConfigMap/Secret, or nothing if it doesn't exist
+func (store *k8sStore) read(name, key string) (data []byte, err error) {
+	var object *K8sStateObject
+	object, err = store.get(name)
+	if err == nil && object != nil {
+		data = []byte(object.Data[key])
+		if store.kind == "secret" {
+			data, err = base64.StdEncoding.DecodeString(object.Data[key])
+		}
+	}
+	return
+}
+
+//write stores data under key in the named ConfigMap/Secret. It's created if it doesn't exist, otherwise key is merge
+// patched using its resourceVersion, so the api server rejects the write if it's been changed underneath us
+func (store *k8sStore) write(name, key string, data []byte) (err error) {
+	var object *K8sStateObject
+	object, err = store.get(name)
+	if err == nil {
+		value := string(data)
+		if store.kind == "secret" {
+			value = base64.StdEncoding.EncodeToString(data)
+		}
+		method := "PATCH"
+		path := store.path(name)
+		var body interface{}
+		if object == nil {
+			method = "POST"
+			path = store.path("")
+			object = &K8sStateObject{APIVersion: "v1", Kind: "ConfigMap", Data: map[string]string{key: value}}
+			if store.kind == "secret" {
+				object.Kind = "Secret"
+			}
+			object.Metadata.Name = name
+			object.Metadata.Namespace = store.namespace
+			body = object
+		} else {
+			patch := &k8sStatePatch{Data: map[string]string{key: value}}
+			patch.Metadata.ResourceVersion = object.Metadata.ResourceVersion
+			body = patch
+		}
+		var resp *http.Response
+		resp, err = store.api.do(method, path, body)
+		if err == nil {
+			defer resp.Body.Close()
+			if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusCreated {
+				err = k8sStatusError(resp, method, path)
+			}
+		}
+	}
+	return
+}
+
+//k8sStore implementation of AlertStore. A missing ConfigMap/Secret is treated as an empty state
+func (store *k8sStore) Load() (alertState *AlertState, err error) {
+	var data []byte
+	data, err = store.read(store.name, stateDataKey)
+	if err == nil {
+		alertState, err = decodeAlertState(data)
+		store.lastSaved = data
+	}
+	return
+}
+
+//k8sStore implementation of AlertStore
+func (store *k8sStore) Save(alertState *AlertState) (err error) {
+	var data []byte
+	data, err = json.Marshal(alertState)
+	if err == nil && !bytes.Equal(data, store.lastSaved) {
+		err = store.write(store.name, stateDataKey, data)
+		if err == nil {
+			store.lastSaved = data
+		}
+	}
+	return
+}
+
+//k8sStore implementation of AlertStore. A missing ConfigMap/Secret is treated as there being no scan yet
+func (store *k8sStore) LoadScan() (scan *Scan, err error) {
+	var data []byte
+	data, err = store.read(store.scanName(), scanDataKey)
+	if err == nil && len(data) > 0 {
+		scan = new(Scan)
+		err = json.Unmarshal(data, scan)
+	}
+	return
+}
+
+//k8sStore implementation of AlertStore
+func (store *k8sStore) SaveScan(scan *Scan) (err error) {
+	var data []byte
+	data, err = json.Marshal(scan)
+	if err == nil {
+		err = store.write(store.scanName(), scanDataKey, data)
+	}
+	return
+}
+
+//k8sStatusError returns an error describing an unexpected response from the k8s master
+func k8sStatusError(resp *http.Response, method, path string) error {
+	body, _ := ioutil.ReadAll(resp.Body)
+	return errors.New("bad status code (" + strconv.Itoa(resp.StatusCode) + ") trying to " + method + " " + path +
+		": " + strings.TrimSpace(string(body)))
+}
@@ -0,0 +1,244 @@
+package main
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+var storeStrings = []struct {
+	storeString string
+	valid       bool
+}{
+	{"", true},
+	{"file:/var/lib/inspectr/state.json", true},
+	{"file:", false},
+	{"configmap:inspectr", false},
+	{"configmap:/inspectr-state", false},
+	{"secret:inspectr/", false},
+	{"banana:inspectr/inspectr-state", false},
+}
+
+func TestAlertStoreFromString(t *testing.T) {
+	for _, storeString := range storeStrings {
+		if _, err := alertStoreFromString(storeString.storeString); (err == nil) != storeString.valid {
+			t.Errorf("alertStoreFromString(%s) returned error %v, expected valid: %t", storeString.storeString,
+				err, storeString.valid)
+		}
+	}
+}
+
+func TestRegisterAlerts(t *testing.T) {
+	now := time.Date(2017, 6, 1, 10, 0, 0, 0, time.UTC)
+	alertState := newAlertState()
+	banana := InspectrResult{Name: "banana", Namespace: "banana-namespace", Upgrades: []string{"v0.0.2"}}
+	apples := InspectrResult{Name: "apples", Namespace: "apples-namespace", Upgrades: []string{"v0.0.2"}}
+	upgradeMap := map[string][]InspectrResult{"banana-key": {banana}}
+	if filtered := alertState.registerAlerts("slack", upgradeMap, false, now); len(filtered) != 1 {
+		t.Errorf("registerAlerts returned %v on first sighting, expected banana-key", filtered)
+	}
+	if filtered := alertState.registerAlerts("slack", upgradeMap, false, now); len(filtered) != 0 {
+		t.Errorf("registerAlerts returned %v on second sighting, expected nothing", filtered)
+	}
+	if filtered := alertState.registerAlerts("jira", upgradeMap, false, now); len(filtered) != 1 {
+		t.Errorf("registerAlerts returned %v for another output, expected banana-key", filtered)
+	}
+	upgradeMap = map[string][]InspectrResult{"apples-key": {apples}}
+	if filtered := alertState.registerAlerts("slack", upgradeMap, true, now); len(filtered) != 1 {
+		t.Errorf("registerAlerts returned %v within the alert window, expected apples-key", filtered)
+	}
+	slackState := alertState.Outputs["slack"]
+	if _, ok := slackState.RegisteredImages["banana-key"]; ok || len(slackState.Alerted) != 1 ||
+		!slackState.Alerted["apples-key"].Equal(now) {
+		t.Errorf("registerAlerts within the alert window left %+v, expected only apples-key", slackState)
+	}
+}
+
+func TestFileStore(t *testing.T) {
+	dir, err := ioutil.TempDir("", "inspectr")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "state.json")
+	store := &fileStore{path: path}
+	alertState, err := store.Load()
+	if err != nil || len(alertState.Outputs) != 0 {
+		t.Errorf("fileStore.Load() with no file returned %+v, %v, expected an empty state", alertState, err)
+	}
+	alertState.registerAlerts("slack", map[string][]InspectrResult{"banana-key": {{Namespace: "banana"}}}, false,
+		time.Now())
+	if err := store.Save(alertState); err != nil {
+		t.Errorf("fileStore.Save() returned %v", err)
+	}
+	loaded, err := (&fileStore{path: path}).Load()
+	if err != nil || len(loaded.Outputs["slack"].RegisteredImages["banana-key"]) != 1 {
+		t.Errorf("fileStore.Load() returned %+v, %v, expected banana-key registered", loaded, err)
+	}
+	if files, _ := ioutil.ReadDir(dir); len(files) != 1 {
+		t.Errorf("fileStore.Save() left %d files behind, expected 1", len(files))
+	}
+	if scan, err := store.LoadScan(); scan != nil || err != nil {
+		t.Errorf("fileStore.LoadScan() with no file returned %+v, %v, expected no scan", scan, err)
+	}
+	start := time.Date(2017, 6, 1, 10, 0, 0, 0, time.UTC)
+	if err := store.SaveScan(newScan(start, start, nil, nil)); err != nil {
+		t.Errorf("fileStore.SaveScan() returned %v", err)
+	}
+	if scan, err := (&fileStore{path: path}).LoadScan(); scan == nil || !scan.Start.Equal(start) || err != nil {
+		t.Errorf("fileStore.LoadScan() returned %+v, %v, expected the saved scan", scan, err)
+	}
+}
+
+//fakeK8sObjects is a fake k8s api that stores ConfigMaps/Secrets by path, enforcing resourceVersions and applying
+// merge patches to metadata and data. Writes bigger than maxSize bytes, if set, are rejected as too large
+type fakeK8sObjects struct {
+	sync.Mutex
+	objects map[string]map[string]interface{}
+	writes  int
+	maxSize int64
+}
+
+func (fake *fakeK8sObjects) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	fake.Lock()
+	defer fake.Unlock()
+	var object map[string]interface{}
+	switch r.Method {
+	case "GET":
+		object, ok := fake.objects[r.URL.Path]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		json.NewEncoder(w).Encode(object)
+	case "POST", "PUT", "PATCH":
+		if fake.maxSize > 0 && r.ContentLength > fake.maxSize {
+			w.WriteHeader(http.StatusRequestEntityTooLarge)
+			return
+		}
+		json.NewDecoder(r.Body).Decode(&object)
+		metadata, _ := object["metadata"].(map[string]interface{})
+		path := r.URL.Path
+		if r.Method == "POST" {
+			path += "/" + metadata["name"].(string)
+		}
+		existing, ok := fake.objects[path]
+		if ok != (r.Method != "POST") || (ok && existing["metadata"].(map[string]interface{})["resourceVersion"] !=
+			metadata["resourceVersion"]) {
+			w.WriteHeader(http.StatusConflict)
+			return
+		}
+		if r.Method == "PATCH" {
+			for _, field := range []string{"metadata", "data"} {
+				patch, _ := object[field].(map[string]interface{})
+				merged, _ := existing[field].(map[string]interface{})
+				if merged == nil {
+					merged = make(map[string]interface{})
+				}
+				for key, value := range patch {
+					merged[key] = value
+				}
+				existing[field] = merged
+			}
+			object, metadata = existing, existing["metadata"].(map[string]interface{})
+		}
+		fake.writes++
+		metadata["resourceVersion"] = time.Now().String()
+		fake.objects[path] = object
+		w.WriteHeader(http.StatusCreated)
+	}
+}
+
+func TestK8sStore(t *testing.T) {
+	for _, kind := range []string{"configmap", "secret"} {
+		fake := &fakeK8sObjects{objects: make(map[string]map[string]interface{})}
+		server := httptest.NewServer(fake)
+		api := &K8sAPI{BaseURL: server.URL, Client: http.DefaultClient}
+		store := &k8sStore{api: api, kind: kind, namespace: "inspectr", name: "inspectr-state"}
+		alertState, err := store.Load()
+		if err != nil || len(alertState.Outputs) != 0 {
+			t.Errorf("%s k8sStore.Load() with no object returned %+v, %v, expected an empty state", kind,
+				alertState, err)
+		}
+		for i, key := range []string{"banana-key", "banana-key", "apples-key"} {
+			alertState.registerAlerts("slack", map[string][]InspectrResult{key: {{Namespace: "banana"}}}, false,
+				time.Date(2017, 6, 1, 10, 0, 0, 0, time.UTC))
+			if err := store.Save(alertState); err != nil {
+				t.Errorf("%s k8sStore.Save() %d returned %v", kind, i, err)
+			}
+			if i == 0 {
+				//someone else labels and annotates the object, and stores something else in it
+				object := fake.objects[store.path(store.name)]
+				metadata := object["metadata"].(map[string]interface{})
+				metadata["labels"] = map[string]interface{}{"app": "inspectr"}
+				metadata["annotations"] = map[string]interface{}{"owner": "platform"}
+				object["data"].(map[string]interface{})["other.json"] = "{}"
+			}
+		}
+		if fake.writes != 2 {
+			t.Errorf("%s k8sStore.Save() wrote %d times, expected 2 as the second save was unchanged", kind,
+				fake.writes)
+		}
+		loaded, err := (&k8sStore{api: api, kind: kind, namespace: "inspectr", name: "inspectr-state"}).Load()
+		if err != nil || len(loaded.Outputs["slack"].RegisteredImages) != 2 {
+			t.Errorf("%s k8sStore.Load() returned %+v, %v, expected banana-key and apples-key", kind, loaded, err)
+		}
+		object := fake.objects[store.path(store.name)]
+		metadata := object["metadata"].(map[string]interface{})
+		if !reflect.DeepEqual(metadata["labels"], map[string]interface{}{"app": "inspectr"}) ||
+			!reflect.DeepEqual(metadata["annotations"], map[string]interface{}{"owner": "platform"}) ||
+			object["data"].(map[string]interface{})["other.json"] != "{}" {
+			t.Errorf("%s k8sStore.Save() left %+v, expected its labels, annotations and other data to be kept", kind,
+				object)
+		}
+		server.Close()
+	}
+}
+
+func TestK8sStoreScan(t *testing.T) {
+	fake := &fakeK8sObjects{objects: make(map[string]map[string]interface{}), maxSize: 2048}
+	server := httptest.NewServer(fake)
+	defer server.Close()
+	api := &K8sAPI{BaseURL: server.URL, Client: http.DefaultClient}
+	store := &k8sStore{api: api, kind: "configmap", namespace: "inspectr", name: "inspectr-state"}
+	alertState, _ := store.Load()
+	alertState.registerAlerts("slack", map[string][]InspectrResult{"banana-key": {{Namespace: "banana"}}}, false,
+		time.Date(2017, 6, 1, 10, 0, 0, 0, time.UTC))
+	start := time.Date(2017, 6, 1, 10, 0, 0, 0, time.UTC)
+	if err := store.SaveScan(newScan(start, start, nil, nil)); err != nil {
+		t.Errorf("k8sStore.SaveScan() returned %v", err)
+	}
+	if scan, err := store.LoadScan(); scan == nil || !scan.Start.Equal(start) || err != nil {
+		t.Errorf("k8sStore.LoadScan() returned %+v, %v, expected the saved scan", scan, err)
+	}
+	upgradeMap := make(map[string][]InspectrResult)
+	for i := 0; i < 100; i++ {
+		upgradeMap["project:cluster:eversc/app"+strconv.Itoa(i)+":app:app"] = []InspectrResult{{Namespace: "default",
+			Version: "v1", Upgrades: []string{"v2"}}}
+	}
+	if err := store.SaveScan(newScan(start.Add(time.Minute), start.Add(time.Minute), upgradeMap, nil)); err == nil {
+		t.Errorf("k8sStore.SaveScan() of a scan too large to store returned nil, expected an error")
+	}
+	if err := store.Save(alertState); err != nil {
+		t.Errorf("k8sStore.Save() after a scan failed to save returned %v", err)
+	}
+	writes := fake.writes
+	if err := store.Save(alertState); err != nil || fake.writes != writes {
+		t.Errorf("k8sStore.Save() of an unchanged state returned %v and wrote %d times, expected no write", err,
+			fake.writes-writes)
+	}
+	if _, ok := fake.objects[store.path(store.name)]["data"].(map[string]interface{})[scanDataKey]; ok {
+		t.Errorf("k8sStore.SaveScan() wrote to the alert state's ConfigMap, expected its own")
+	}
+	if scan, err := store.LoadScan(); scan == nil || !scan.Start.Equal(start) || err != nil {
+		t.Errorf("k8sStore.LoadScan() returned %+v, %v, expected the last scan that could be saved", scan, err)
+	}
+}
@@ -0,0 +1,183 @@
+package main
+
+import (
+	"encoding/json"
+	"strconv"
+	"strings"
+)
+
+//maxTeamsMessageBytes keeps each message's card comfortably under the payload size a teams webhook rejects (~28KB)
+const maxTeamsMessageBytes = 25000
+
+//teamsUpgradeClassColors are the colours of each image's title, for the most significant class of upgrade it has
+// available
+var teamsUpgradeClassColors = map[string]string{
+	"major":   "Attention",
+	"minor":   "Warning",
+	"patch":   "Accent",
+	"unknown": "Default",
+}
+
+//TeamsMsg type representing the json schema of a message posted to a teams incoming webhook
+type TeamsMsg struct {
+	Type        string            `json:"type"`
+	Attachments []TeamsAttachment `json:"attachments"`
+}
+
+//TeamsAttachment type representing a card attached to a teams message
+type TeamsAttachment struct {
+	ContentType string    `json:"contentType"`
+	Content     TeamsCard `json:"content"`
+}
+
+//TeamsCard type representing an Adaptive Card, see https://adaptivecards.io/explorer/
+type TeamsCard struct {
+	Schema  string         `json:"$schema"`
+	Type    string         `json:"type"`
+	Version string         `json:"version"`
+	Body    []TeamsElement `json:"body"`
+}
+
+//TeamsElement type representing an Adaptive Card element (TextBlock, Container or FactSet)
+type TeamsElement struct {
+	Type      string         `json:"type"`
+	Text      string         `json:"text,omitempty"`
+	Size      string         `json:"size,omitempty"`
+	Weight    string         `json:"weight,omitempty"`
+	Color     string         `json:"color,omitempty"`
+	IsSubtle  bool           `json:"isSubtle,omitempty"`
+	Wrap      bool           `json:"wrap,omitempty"`
+	Separator bool           `json:"separator,omitempty"`
+	Items     []TeamsElement `json:"items,omitempty"`
+	Facts     []TeamsFact    `json:"facts,omitempty"`
+}
+
+//TeamsFact type representing a title and value in an Adaptive Card FactSet
+type TeamsFact struct {
+	Title string `json:"title"`
+	Value string `json:"value"`
+}
+
+//teamsNotifier type: a Notifier that posts to a teams incoming webhook
+type teamsNotifier struct {
+	config TeamsConfig
+}
+
+//teamsNotifier implementation of Notifier
+func (notifier *teamsNotifier) FullReport(upgradeMap map[string][]InspectrResult) error {
+	return notifier.postResults(upgradeMap, true)
+}
+
+//teamsNotifier implementation of Notifier
+func (notifier *teamsNotifier) NewFindings(upgradeMap map[string][]InspectrResult) error {
+	return notifier.postResults(upgradeMap, false)
+}
+
+//teamsNotifier implementation of Notifier
+func (notifier *teamsNotifier) Event(event NotifierEvent) error {
+	return notifier.post(teamsMsg([]TeamsElement{{Type: "TextBlock", Wrap: true,
+		Text: event.description() + " for " + imageFromInspectrMapKey(event.MapKey)}}))
+}
+
+//postResults posts the results as Adaptive Cards, stopping at the first that can't be posted
+func (notifier *teamsNotifier) postResults(upgradeMap map[string][]InspectrResult, fullReport bool) (err error) {
+	for _, msg := range teamsMessages(upgradeMap, fullReport) {
+		err = notifier.post(msg)
+		if err != nil {
+			break
+		}
+	}
+	return
+}
+
+//post posts the message to the webhook. Errors don't include the webhook's url, as anyone with it can post to the
+// channel
+func (notifier *teamsNotifier) post(msg TeamsMsg) error {
+	return postJSON(notifier.config.HTTP.httpClient(), notifier.config.WebhookURL, "teams webhook", msg)
+}
+
+//teamsMessages returns the specified results as Adaptive Card messages: a header summarising them, then a container
+// for each image with the same details posted to slack. The containers are split across as many messages as it takes
+// to keep each within teams' size limit, with the header numbering them
+func teamsMessages(upgradeMap map[string][]InspectrResult, fullReport bool) (msgs []TeamsMsg) {
+	header := slackHeader(upgradeMap, fullReport)
+	var parts [][]TeamsElement
+	var body []TeamsElement
+	size := 0
+	for _, k := range sortedSlackKeys(upgradeMap) {
+		container := teamsImageContainer(k, upgradeMap[k])
+		containerJSON, _ := json.Marshal(container)
+		if len(body) > 0 && size+len(containerJSON) > maxTeamsMessageBytes {
+			parts = append(parts, body)
+			body = nil
+			size = 0
+		}
+		body = append(body, container)
+		size += len(containerJSON)
+	}
+	if len(body) > 0 || len(parts) == 0 {
+		parts = append(parts, body)
+	}
+	for i, part := range parts {
+		title := header
+		if len(parts) > 1 {
+			title += " (part " + strconv.Itoa(i+1) + " of " + strconv.Itoa(len(parts)) + ")"
+		}
+		elements := []TeamsElement{{Type: "TextBlock", Text: title, Size: "Large", Weight: "Bolder", Wrap: true}}
+		if i == 0 {
+			elements = append(elements, TeamsElement{Type: "TextBlock", Text: teamsSummary(upgradeMap),
+				IsSubtle: true, Wrap: true})
+		}
+		msgs = append(msgs, teamsMsg(append(elements, part...)))
+	}
+	return
+}
+
+//teamsMsg returns a message with an Adaptive Card with the specified body
+func teamsMsg(body []TeamsElement) TeamsMsg {
+	return TeamsMsg{Type: "message", Attachments: []TeamsAttachment{{
+		ContentType: "application/vnd.microsoft.card.adaptive",
+		Content: TeamsCard{Schema: "http://adaptivecards.io/schemas/adaptive-card.json", Type: "AdaptiveCard",
+			Version: "1.4", Body: body},
+	}}}
+}
+
+//teamsSummary returns a plain text line summarising the specified results (see summaryCounts), e.g. "2 major,
+// 1 patch across 3 clusters". Email uses it too
+func teamsSummary(upgradeMap map[string][]InspectrResult) (summary string) {
+	classCounts, clusters := summaryCounts(upgradeMap)
+	var classStrings []string
+	for _, class := range validUpgradeClasses {
+		if classCounts[class] > 0 {
+			classStrings = append(classStrings, strconv.Itoa(classCounts[class])+" "+class)
+		}
+	}
+	summary = "nothing to upgrade"
+	if len(classStrings) > 0 {
+		summary = strings.Join(classStrings, ", ") + " across " + countString(clusters, "cluster")
+	}
+	return
+}
+
+//teamsImageContainer returns a container detailing the results for an image (under the specified results map key)
+func teamsImageContainer(mapKey string, inspectrResults []InspectrResult) (container TeamsElement) {
+	class := mostSignificantUpgradeClass(inspectrResults)
+	facts := []TeamsFact{
+		{"cluster", projectFromInspectrMapKey(mapKey) + "/" + clusterFromInspectrMapKey(mapKey)},
+		{"workload", podFromInspectrMapKey(mapKey) + "/" + containerFromInspectrMapKey(mapKey)},
+		{"namespaces", namespaceStringFromInspectrResults(inspectrResults)},
+		{"current versions", currentVersionStringFromInspectrResults(inspectrResults)},
+		{"new versions", newVersionStringFromInspectrResults(inspectrResults)},
+		{"versions behind", versionsBehindStringFromInspectrResults(inspectrResults)},
+		{"days behind", daysBehindStringFromInspectrResults(inspectrResults)},
+	}
+	if owners := ownerStringFromInspectrResults(inspectrResults); owners != "" {
+		facts = append(facts, TeamsFact{"owner", owners})
+	}
+	container = TeamsElement{Type: "Container", Separator: true, Items: []TeamsElement{
+		{Type: "TextBlock", Text: imageFromInspectrMapKey(mapKey) + " (" + class + " upgrade)", Weight: "Bolder",
+			Color: teamsUpgradeClassColors[class], Wrap: true},
+		{Type: "FactSet", Facts: facts},
+	}}
+	return
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestTeamsMessages(t *testing.T) {
+	upgradeMap := map[string][]InspectrResult{
+		"project:prod:eversc/db:db:db": {{Namespace: "data", Version: "1.0.0", Upgrades: []string{"1.1.0", "2.0.0"},
+			UpgradeClass: "major", VersionsBehind: 2, DaysBehind: -1, Ownership: Ownership{Team: "storage"}}},
+	}
+	msgs := teamsMessages(upgradeMap, true)
+	if len(msgs) != 1 || msgs[0].Attachments[0].ContentType != "application/vnd.microsoft.card.adaptive" {
+		t.Fatalf("teamsMessages returned %#v", msgs)
+	}
+	body := msgs[0].Attachments[0].Content.Body
+	if body[0].Text != "inspectr report: 1 image with upgrades available" || body[1].Text != "1 major across 1 cluster" {
+		t.Errorf("teamsMessages returned header %s, summary %s", body[0].Text, body[1].Text)
+	}
+	image := body[2].Items
+	if image[0].Text != "eversc/db (major upgrade)" || image[0].Color != "Attention" {
+		t.Errorf("teamsMessages returned image title %#v", image[0])
+	}
+	facts := make(map[string]string)
+	for _, fact := range image[1].Facts {
+		facts[fact.Title] = fact.Value
+	}
+	if facts["cluster"] != "project/prod" || facts["namespaces"] != "data" || facts["current versions"] != "1.0.0" ||
+		facts["new versions"] != "1.1.0, 2.0.0" || facts["days behind"] != "?" || facts["owner"] != "storage" {
+		t.Errorf("teamsMessages returned facts %v", facts)
+	}
+	if v := teamsMessages(nil, false)[0].Attachments[0].Content.Body; len(v) != 2 ||
+		v[0].Text != "inspectr: new upgrades for 0 images" {
+		t.Errorf("teamsMessages returned body %#v for no new findings", v)
+	}
+}
+
+func TestTeamsMessagesSplit(t *testing.T) {
+	upgradeMap := make(map[string][]InspectrResult)
+	for i := 0; i < 300; i++ {
+		key := "project:prod:eversc/app" + strconv.Itoa(i) + ":app:app"
+		upgradeMap[key] = []InspectrResult{{Namespace: "ns", Version: "1.0.0", Upgrades: []string{"1.1.0"}}}
+	}
+	msgs := teamsMessages(upgradeMap, true)
+	if len(msgs) < 2 {
+		t.Fatalf("teamsMessages returned %d messages for 300 images, expected them split", len(msgs))
+	}
+	containers := 0
+	for i, msg := range msgs {
+		payload, _ := json.Marshal(msg)
+		body := msg.Attachments[0].Content.Body
+		if len(payload) > maxTeamsMessageBytes+1000 || !strings.HasSuffix(body[0].Text,
+			"(part "+strconv.Itoa(i+1)+" of "+strconv.Itoa(len(msgs))+")") {
+			t.Errorf("message %d has %d bytes and title %s", i, len(payload), body[0].Text)
+		}
+		for _, element := range body {
+			if element.Type == "Container" {
+				containers++
+			}
+		}
+	}
+	if containers != 300 {
+		t.Errorf("teamsMessages returned %d image containers, expected 300", containers)
+	}
+}
+
+func TestTeamsNotifier(t *testing.T) {
+	var msgs []TeamsMsg
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/webhook/gone" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		var msg TeamsMsg
+		json.NewDecoder(r.Body).Decode(&msg)
+		msgs = append(msgs, msg)
+	}))
+	defer server.Close()
+	notifier := &teamsNotifier{config: TeamsConfig{WebhookURL: server.URL + "/webhook/ok"}}
+	if err := notifier.NewFindings(map[string][]InspectrResult{"project:prod:eversc/app:app:app": {{}}}); err != nil {
+		t.Fatal(err)
+	}
+	err := notifier.Event(NotifierEvent{"jira", jiraCommentedEvent, "project:prod:eversc/app:app:app",
+		"https://jira.example.com/browse/OPS-1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(msgs) != 2 || msgs[1].Attachments[0].Content.Body[0].Text !=
+		"just commented on https://jira.example.com/browse/OPS-1 for eversc/app" {
+		t.Errorf("teams webhook was sent %#v", msgs)
+	}
+	notifier.config.WebhookURL = server.URL + "/webhook/gone"
+	if err = notifier.FullReport(nil); err == nil || !strings.HasPrefix(err.Error(), "teams webhook returned status 404") {
+		t.Errorf("FullReport to a missing webhook returned error %v", err)
+	}
+}
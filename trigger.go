@@ -0,0 +1,109 @@
+package main
+
+import (
+	"crypto/subtle"
+	"errors"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+//scanTriggers receives a bool, indicating whether a full report is wanted, whenever an immediate scan is requested
+var scanTriggers = make(chan bool, 1)
+
+//triggerScan requests an immediate scan, returning false if one is already pending
+func triggerScan(fullReport bool) (triggered bool) {
+	select {
+	case scanTriggers <- fullReport:
+		triggered = true
+	default:
+	}
+	return
+}
+
+//handleTrigger returns a handler that triggers an immediate scan on POST, optionally forcing a full report to all
+// outputs with ?full=true. Requests must carry "Authorization: Bearer [apiToken]"; if apiToken is empty the endpoint
+// is disabled
+func handleTrigger(apiToken string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "POST" {
+			w.Header().Set("Allow", "POST")
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		if apiToken == "" {
+			http.Error(w, "scan trigger is disabled, set the INSPECTR_API_TOKEN env var to enable it",
+				http.StatusForbidden)
+			return
+		}
+		if !validBearerToken(r.Header.Get("Authorization"), apiToken) {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		if !isLeader() {
+			http.Error(w, "not the leader, trigger the leader instead", http.StatusServiceUnavailable)
+			return
+		}
+		fullReport, _ := strconv.ParseBool(r.URL.Query().Get("full"))
+		if !triggerScan(fullReport) {
+			http.Error(w, "a scan is already pending", http.StatusConflict)
+			return
+		}
+		w.WriteHeader(http.StatusAccepted)
+		w.Write([]byte("scan triggered\n"))
+	}
+}
+
+//validBearerToken returns a bool indicating whether the Authorization header carries the specified bearer token
+func validBearerToken(authorization, apiToken string) bool {
+	token := strings.TrimPrefix(authorization, "Bearer ")
+	return token != authorization && subtle.ConstantTimeCompare([]byte(token), []byte(apiToken)) == 1
+}
+
+//runTriggerCommand implements the "trigger" subcommand, asking a running inspectr to scan immediately. It returns
+// the process exit code
+//
+//     inspectr trigger [-full] [-url http://localhost:8080] [-token token]
+func runTriggerCommand(args []string, apiToken string) int {
+	flags := flag.NewFlagSet("trigger", flag.ContinueOnError)
+	fullReport := flags.Bool("full", false, "output the full set of results to all outputs, not just new ones")
+	url := flags.String("url", "http://localhost:8080", "base URL of the inspectr to trigger")
+	token := flags.String("token", apiToken, "API token, defaults to the INSPECTR_API_TOKEN env var")
+	if flags.Parse(args) != nil {
+		return 2
+	}
+	err := postTrigger(*url, *token, *fullReport)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	fmt.Println("scan triggered")
+	return 0
+}
+
+//postTrigger asks the inspectr at the specified base URL to scan immediately
+func postTrigger(baseURL, token string, fullReport bool) (err error) {
+	req, _ := http.NewRequest("POST", strings.TrimSuffix(baseURL, "/")+"/api/v1/scan?full="+
+		strconv.FormatBool(fullReport), nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	client := http.Client{
+		Timeout: 30 * time.Second,
+	}
+	var resp *http.Response
+	resp, err = client.Do(req)
+	if err == nil {
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusAccepted {
+			body, _ := ioutil.ReadAll(resp.Body)
+			err = errors.New("bad status code (" + strconv.Itoa(resp.StatusCode) + ") triggering scan: " +
+				strings.TrimSpace(string(body)))
+		}
+	}
+	return
+}
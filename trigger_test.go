@@ -0,0 +1,75 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+var triggerRequests = []struct {
+	apiToken      string
+	method        string
+	authorization string
+	query         string
+	status        int
+	fullReport    bool
+}{
+	{"secret", "POST", "Bearer secret", "", http.StatusAccepted, false},
+	{"secret", "POST", "Bearer secret", "?full=true", http.StatusAccepted, true},
+	{"secret", "POST", "Bearer banana", "", http.StatusUnauthorized, false},
+	{"secret", "POST", "secret", "", http.StatusUnauthorized, false},
+	{"secret", "POST", "", "", http.StatusUnauthorized, false},
+	{"secret", "GET", "Bearer secret", "", http.StatusMethodNotAllowed, false},
+	{"", "POST", "Bearer ", "", http.StatusForbidden, false},
+}
+
+func TestHandleTrigger(t *testing.T) {
+	for _, triggerRequest := range triggerRequests {
+		req := httptest.NewRequest(triggerRequest.method, "/api/v1/scan"+triggerRequest.query, nil)
+		req.Header.Set("Authorization", triggerRequest.authorization)
+		recorder := httptest.NewRecorder()
+		handleTrigger(triggerRequest.apiToken)(recorder, req)
+		if recorder.Code != triggerRequest.status {
+			t.Errorf("handleTrigger(%+v) returned %d, expected %d", triggerRequest, recorder.Code,
+				triggerRequest.status)
+		}
+		select {
+		case fullReport := <-scanTriggers:
+			if recorder.Code != http.StatusAccepted || fullReport != triggerRequest.fullReport {
+				t.Errorf("handleTrigger(%+v) triggered a scan with full report %t", triggerRequest, fullReport)
+			}
+		default:
+			if recorder.Code == http.StatusAccepted {
+				t.Errorf("handleTrigger(%+v) accepted but didn't trigger a scan", triggerRequest)
+			}
+		}
+	}
+
+	setLeader(false)
+	defer setLeader(true)
+	req := httptest.NewRequest("POST", "/api/v1/scan", nil)
+	req.Header.Set("Authorization", "Bearer secret")
+	recorder := httptest.NewRecorder()
+	handleTrigger("secret")(recorder, req)
+	if recorder.Code != http.StatusServiceUnavailable {
+		t.Errorf("handleTrigger on a follower returned %d, expected %d", recorder.Code,
+			http.StatusServiceUnavailable)
+	}
+}
+
+func TestPostTrigger(t *testing.T) {
+	server := httptest.NewServer(handleTrigger("secret"))
+	defer server.Close()
+	if err := postTrigger(server.URL, "secret", true); err != nil {
+		t.Errorf("postTrigger returned %s, expected no error", err)
+	}
+	if err := postTrigger(server.URL, "secret", false); err == nil {
+		t.Errorf("postTrigger returned no error while a scan was already pending")
+	}
+	if fullReport := <-scanTriggers; !fullReport {
+		t.Errorf("postTrigger triggered a scan without a full report")
+	}
+	if err := postTrigger(server.URL, "banana", false); err == nil {
+		t.Errorf("postTrigger returned no error with a bad token")
+	}
+}
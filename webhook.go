@@ -0,0 +1,124 @@
+package main
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"text/template"
+	"time"
+)
+
+//defaultWebhookSignatureHeader is the header the body's signature is sent in, unless configured otherwise
+const defaultWebhookSignatureHeader = "X-Inspectr-Signature"
+
+//kinds of WebhookPayload, other than a NotifierEvent's kind
+const (
+	fullReportPayload  = "full-report"
+	newFindingsPayload = "new-findings"
+)
+
+//webhookTemplateFuncs are available in webhook templates, as well as text/template's own
+var webhookTemplateFuncs = template.FuncMap{
+	"json": func(v interface{}) (string, error) {
+		b, err := json.Marshal(v)
+		return string(b), err
+	},
+	"join": strings.Join,
+}
+
+//WebhookPayload type representing the data a webhook's template is rendered with. Kind is fullReportPayload,
+// newFindingsPayload, or the kind of the Event (e.g. jiraCreatedEvent), in which case Results is empty
+type WebhookPayload struct {
+	Kind    string         `json:"kind"`
+	Header  string         `json:"header"`
+	Results []APIResult    `json:"results"`
+	Event   *NotifierEvent `json:"event,omitempty"`
+}
+
+//webhookNotifier type: a Notifier that sends each payload to a generic webhook, rendered with its template
+type webhookNotifier struct {
+	config WebhookConfig
+}
+
+//webhookNotifier implementation of Notifier
+func (notifier *webhookNotifier) FullReport(upgradeMap map[string][]InspectrResult) error {
+	return notifier.send(WebhookPayload{Kind: fullReportPayload, Header: slackHeader(upgradeMap, true),
+		Results: newScan(time.Time{}, time.Time{}, upgradeMap, nil).Results})
+}
+
+//webhookNotifier implementation of Notifier
+func (notifier *webhookNotifier) NewFindings(upgradeMap map[string][]InspectrResult) error {
+	return notifier.send(WebhookPayload{Kind: newFindingsPayload, Header: slackHeader(upgradeMap, false),
+		Results: newScan(time.Time{}, time.Time{}, upgradeMap, nil).Results})
+}
+
+//webhookNotifier implementation of Notifier
+func (notifier *webhookNotifier) Event(event NotifierEvent) error {
+	return notifier.send(WebhookPayload{Kind: event.Kind, Header: event.description() + " for " +
+		imageFromInspectrMapKey(event.MapKey), Results: make([]APIResult, 0), Event: &event})
+}
+
+//send renders the payload with the webhook's template and sends it, signed if there's a secret, returning an error
+// if the webhook doesn't respond with a 2xx status. Errors don't include the webhook's url, which may be secret
+func (notifier *webhookNotifier) send(payload WebhookPayload) (err error) {
+	tmpl := notifier.config.template
+	if tmpl == nil {
+		tmpl, err = webhookTemplate(notifier.config.Template)
+	}
+	var body bytes.Buffer
+	if err == nil {
+		err = tmpl.Execute(&body, payload)
+	}
+	var req *http.Request
+	if err == nil {
+		method := notifier.config.Method
+		if method == "" {
+			method = "POST"
+		}
+		req, err = http.NewRequest(method, notifier.config.URL, bytes.NewReader(body.Bytes()))
+	}
+	if err == nil {
+		if notifier.config.Template == "" {
+			req.Header.Set("Content-Type", "application/json; charset=utf-8")
+		}
+		for k, v := range notifier.config.Headers {
+			req.Header.Set(k, v)
+		}
+		if notifier.config.Secret != "" {
+			signatureHeader := notifier.config.SignatureHeader
+			if signatureHeader == "" {
+				signatureHeader = defaultWebhookSignatureHeader
+			}
+			req.Header.Set(signatureHeader, webhookSignature(body.Bytes(), notifier.config.Secret))
+		}
+		var resp *http.Response
+		resp, err = notifier.config.HTTP.httpClient().Do(req)
+		if err == nil {
+			defer resp.Body.Close()
+			err = statusError("webhook", resp)
+		}
+		err = redactedURLError("webhook", err)
+	}
+	return
+}
+
+//webhookTemplate returns the parsed webhook template, or one rendering the json of its data if it's empty
+func webhookTemplate(text string) (tmpl *template.Template, err error) {
+	if text == "" {
+		text = "{{json .}}"
+	}
+	tmpl, err = template.New("webhook").Funcs(webhookTemplateFuncs).Parse(text)
+	return
+}
+
+//webhookSignature returns the signature of the body with the secret: "sha256=" followed by its hex encoded
+// HMAC-SHA256, as GitHub signs its webhooks
+func webhookSignature(body []byte, secret string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+var webhookTemplates = []struct {
+	template string
+	body     string
+}{
+	{"", `{"kind":"new-findings","header":"inspectr: new upgrades for 1 image","results":[{"project":"project",` +
+		`"cluster":"prod","namespace":"web","workload":"app","container":"app","image":"eversc/app","quantity":0,` +
+		`"version":"1.0.0","latestVersion":"1.1.0","upgrades":["1.1.0"],"versionsBehind":1,"daysBehind":0,` +
+		`"upgradeClass":"minor","firstSeen":{},"owner":{}}]}`},
+	{`{{.Header}}:{{range .Results}} {{.Image}} {{.Version}} -> {{join .Upgrades ","}}{{end}}`,
+		"inspectr: new upgrades for 1 image: eversc/app 1.0.0 -> 1.1.0"},
+	{`{"text": {{json .Header}}}`, `{"text": "inspectr: new upgrades for 1 image"}`},
+}
+
+func TestWebhookNotifier(t *testing.T) {
+	var method, body string
+	var header http.Header
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := ioutil.ReadAll(r.Body)
+		method, body, header = r.Method, string(b), r.Header
+		if r.URL.Path == "/gone" {
+			w.WriteHeader(http.StatusGone)
+		}
+	}))
+	defer server.Close()
+	upgradeMap := map[string][]InspectrResult{"project:prod:eversc/app:app:app": {{Namespace: "web", Version: "1.0.0",
+		LatestVersion: "1.1.0", Upgrades: []string{"1.1.0"}, UpgradeClass: "minor", VersionsBehind: 1}}}
+	for _, webhookTemplate := range webhookTemplates {
+		notifier := &webhookNotifier{config: WebhookConfig{URL: server.URL, Template: webhookTemplate.template}}
+		if err := notifier.NewFindings(upgradeMap); err != nil || body != webhookTemplate.body {
+			t.Errorf("template %s sent body %s, error %v, expected %s", webhookTemplate.template, body, err,
+				webhookTemplate.body)
+		}
+	}
+	notifier := &webhookNotifier{config: WebhookConfig{URL: server.URL, Method: "PUT",
+		Headers: map[string]string{"Authorization": "Bearer abc"}, Secret: "s3cret"}}
+	if err := notifier.Event(NotifierEvent{"jira", jiraCreatedEvent, "project:prod:eversc/app:app:app",
+		"https://jira.example.com/browse/OPS-1"}); err != nil {
+		t.Fatal(err)
+	}
+	var payload WebhookPayload
+	json.Unmarshal([]byte(body), &payload)
+	if method != "PUT" || header.Get("Authorization") != "Bearer abc" || payload.Event == nil ||
+		payload.Header != "just created https://jira.example.com/browse/OPS-1 for eversc/app" {
+		t.Errorf("event was sent as %s %v %s", method, header, body)
+	}
+	if v := header.Get("X-Inspectr-Signature"); v != webhookSignature([]byte(body), "s3cret") ||
+		!strings.HasPrefix(v, "sha256=") || len(v) != 71 {
+		t.Errorf("event was signed %s", v)
+	}
+	notifier.config.URL = server.URL + "/gone"
+	if err := notifier.FullReport(upgradeMap); err == nil || err.Error() != "webhook returned status 410: " {
+		t.Errorf("FullReport to a gone webhook returned error %v", err)
+	}
+}